@@ -0,0 +1,554 @@
+package blindtest
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	aliasSourceDispute = "dispute"
+	aliasSourceAdmin   = "admin"
+	aliasSourceImport  = "import"
+	aliasSourceBuiltin = "builtin"
+	minAliasLength     = 3
+	maxAliasLength     = 100
+	maxAliasImportSize = 1 << 20
+	maxImportErrors    = 20
+)
+
+var (
+	errAliasNotFound = errors.New("alias not found")
+	errAliasInvalid  = errors.New("invalid alias")
+)
+
+type aliasKey struct {
+	kind   string
+	artist string
+	target string
+}
+
+type aliasEntry struct {
+	ID        int64      `json:"id"`
+	Kind      string     `json:"kind"`
+	Artist    string     `json:"artist"`
+	Target    string     `json:"target"`
+	Alias     string     `json:"alias"`
+	Source    string     `json:"source"`
+	Hits      int        `json:"hits"`
+	LastHitAt *time.Time `json:"lastHitAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+type aliasRequest struct {
+	Kind   string `json:"kind"`
+	Artist string `json:"artist"`
+	Title  string `json:"title"`
+	Album  string `json:"album"`
+	Alias  string `json:"alias"`
+}
+
+type aliasImportReport struct {
+	Imported   int      `json:"imported"`
+	Duplicates int      `json:"duplicates"`
+	Invalid    int      `json:"invalid"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+var (
+	aliasesMu sync.RWMutex
+	aliases   = make(map[aliasKey][]string)
+
+	builtinAliases = map[string][]string{
+		"gims":             {"maitre gims"},
+		"maitre gims":      {"gims"},
+		"black m":          {"black mesrimes"},
+		"claude francois":  {"cloclo"},
+		"johnny hallyday":  {"johnny"},
+		"edith piaf":       {"piaf"},
+		"charles aznavour": {"aznavour"},
+		"jacques brel":     {"brel"},
+	}
+)
+
+func createAliasTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS blindtest_aliases (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			artist_key TEXT NOT NULL,
+			target_key TEXT NOT NULL,
+			alias TEXT NOT NULL,
+			source TEXT NOT NULL DEFAULT 'dispute',
+			hits INTEGER DEFAULT 0,
+			last_hit_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (kind, artist_key, target_key, alias)
+		);`,
+		`CREATE TABLE IF NOT EXISTS blindtest_admins (
+			user_id INTEGER PRIMARY KEY REFERENCES users(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS blindtest_meta (
+			key TEXT PRIMARY KEY,
+			value TEXT
+		);`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return seedAliases(db)
+}
+
+// seedAliases inserts the built-in aliases once, so that deleting one sticks.
+func seedAliases(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT OR IGNORE INTO blindtest_meta(key, value) VALUES('builtin_aliases', CURRENT_TIMESTAMP)`)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	for artist, spellings := range builtinAliases {
+		key, ok := aliasKeyFor(modeArtist, artist, "")
+		if !ok {
+			continue
+		}
+		for _, spelling := range spellings {
+			alias, ok := normalizeAlias(key, spelling)
+			if !ok {
+				continue
+			}
+			if _, err := insertAlias(tx.Exec, key, alias, aliasSourceBuiltin); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func loadAliases(db *sql.DB) error {
+	rows, err := db.Query(`SELECT kind, artist_key, target_key, alias FROM blindtest_aliases`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key aliasKey
+		var alias string
+		if err := rows.Scan(&key.kind, &key.artist, &key.target, &alias); err != nil {
+			return err
+		}
+		if _, ok := normalizeAlias(key, alias); !ok {
+			continue
+		}
+		storeAlias(key, alias)
+	}
+	return rows.Err()
+}
+
+func aliasKeyFor(kind, artist, target string) (aliasKey, bool) {
+	key := aliasKey{
+		kind:   kind,
+		artist: normalizeAnswer(cleanTarget(artist)),
+	}
+	switch kind {
+	case modeTitle, modeAlbum:
+		key.target = normalizeAnswer(cleanTarget(target))
+	case modeArtist:
+		key.target = key.artist
+	default:
+		return key, false
+	}
+	return key, key.artist != "" && key.target != ""
+}
+
+func trackAliasKey(kind string, track *Track) (aliasKey, bool) {
+	switch kind {
+	case modeTitle:
+		return aliasKeyFor(kind, track.Artist, track.Title)
+	case modeAlbum:
+		return aliasKeyFor(kind, track.Artist, track.Album)
+	}
+	return aliasKeyFor(kind, track.Artist, "")
+}
+
+func normalizeAlias(key aliasKey, spelling string) (string, bool) {
+	alias := normalizeAnswer(spelling)
+	length := len([]rune(compact(alias)))
+	return alias, alias != key.target && length >= minAliasLength && len([]rune(alias)) <= maxAliasLength
+}
+
+func trackAliases(kind string, track *Track) []string {
+	key, ok := trackAliasKey(kind, track)
+	if !ok {
+		return nil
+	}
+	aliasesMu.RLock()
+	defer aliasesMu.RUnlock()
+	return aliases[key]
+}
+
+func storeAlias(key aliasKey, alias string) bool {
+	aliasesMu.Lock()
+	defer aliasesMu.Unlock()
+
+	for _, existing := range aliases[key] {
+		if existing == alias {
+			return false
+		}
+	}
+	aliases[key] = append(aliases[key], alias)
+	return true
+}
+
+func forgetAlias(key aliasKey, alias string) {
+	aliasesMu.Lock()
+	defer aliasesMu.Unlock()
+
+	var kept []string
+	for _, existing := range aliases[key] {
+		if existing != alias {
+			kept = append(kept, existing)
+		}
+	}
+	if len(kept) == 0 {
+		delete(aliases, key)
+		return
+	}
+	aliases[key] = kept
+}
+
+func addAlias(kind string, track *Track, spelling string) {
+	key, ok := trackAliasKey(kind, track)
+	if !ok {
+		return
+	}
+	alias, ok := normalizeAlias(key, spelling)
+	if !ok || !storeAlias(key, alias) {
+		return
+	}
+
+	queueHistory(func(db *sql.DB) {
+		_, err := db.Exec(`INSERT OR IGNORE INTO blindtest_aliases(kind, artist_key, target_key, alias, source) VALUES(?, ?, ?, ?, ?)`,
+			key.kind, key.artist, key.target, alias, aliasSourceDispute)
+		if err != nil {
+			log.Println("BlindTest: add alias:", err)
+		}
+	})
+}
+
+func recordAliasHits(track *Track, result matchResult, mode string) {
+	hits := map[string]string{
+		modeTitle:  result.TitleAlias,
+		modeArtist: result.ArtistAlias,
+		modeAlbum:  result.AlbumAlias,
+	}
+	for kind, alias := range hits {
+		if alias == "" || (mode == modeClassic && kind == modeAlbum) || (mode != modeClassic && kind != mode) {
+			continue
+		}
+		key, ok := trackAliasKey(kind, track)
+		if !ok {
+			continue
+		}
+		queueHistory(func(db *sql.DB) {
+			_, err := db.Exec(`UPDATE blindtest_aliases SET hits = hits + 1, last_hit_at = CURRENT_TIMESTAMP
+				WHERE kind = ? AND artist_key = ? AND target_key = ? AND alias = ?`,
+				key.kind, key.artist, key.target, alias)
+			if err != nil {
+				log.Println("BlindTest: alias hit:", err)
+			}
+		})
+	}
+}
+
+func isBlindTestAdmin(userID int) bool {
+	if btDB == nil || userID == 0 {
+		return false
+	}
+	var exists int
+	err := btDB.QueryRow(`SELECT 1 FROM blindtest_admins WHERE user_id = ?`, userID).Scan(&exists)
+	return err == nil
+}
+
+func insertAlias(exec func(string, ...interface{}) (sql.Result, error), key aliasKey, alias, source string) (bool, error) {
+	res, err := exec(`INSERT OR IGNORE INTO blindtest_aliases(kind, artist_key, target_key, alias, source) VALUES(?, ?, ?, ?, ?)`,
+		key.kind, key.artist, key.target, alias, source)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func createAlias(req aliasRequest) (int64, error) {
+	target := req.Title
+	if req.Kind == modeAlbum {
+		target = req.Album
+	}
+	key, ok := aliasKeyFor(req.Kind, req.Artist, target)
+	if !ok {
+		return 0, errAliasInvalid
+	}
+	alias, ok := normalizeAlias(key, req.Alias)
+	if !ok {
+		return 0, errAliasInvalid
+	}
+
+	if _, err := insertAlias(btDB.Exec, key, alias, aliasSourceAdmin); err != nil {
+		return 0, err
+	}
+	storeAlias(key, alias)
+
+	var id int64
+	err := btDB.QueryRow(`SELECT id FROM blindtest_aliases WHERE kind = ? AND artist_key = ? AND target_key = ? AND alias = ?`,
+		key.kind, key.artist, key.target, alias).Scan(&id)
+	return id, err
+}
+
+func deleteAlias(id int64) error {
+	var key aliasKey
+	var alias string
+	err := btDB.QueryRow(`SELECT kind, artist_key, target_key, alias FROM blindtest_aliases WHERE id = ?`, id).
+		Scan(&key.kind, &key.artist, &key.target, &alias)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errAliasNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := btDB.Exec(`DELETE FROM blindtest_aliases WHERE id = ?`, id); err != nil {
+		return err
+	}
+	forgetAlias(key, alias)
+	return nil
+}
+
+func fetchAliases(kind, artist string) ([]aliasEntry, error) {
+	rows, err := btDB.Query(`SELECT id, kind, artist_key, target_key, alias, source, COALESCE(hits, 0), last_hit_at, created_at
+		FROM blindtest_aliases
+		WHERE (? = '' OR kind = ?) AND (? = '' OR artist_key = ?)
+		ORDER BY hits DESC, artist_key, target_key, alias`,
+		kind, kind, artist, artist)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []aliasEntry{}
+	for rows.Next() {
+		var e aliasEntry
+		var lastHit sql.NullTime
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Artist, &e.Target, &e.Alias, &e.Source, &e.Hits, &lastHit, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if lastHit.Valid {
+			e.LastHitAt = &lastHit.Time
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func importAliases(r io.Reader) (aliasImportReport, error) {
+	report := aliasImportReport{}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	tx, err := btDB.Begin()
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+
+	type pending struct {
+		key   aliasKey
+		alias string
+	}
+	var added []pending
+	invalid := func(line int, msg string) {
+		report.Invalid++
+		if len(report.Errors) < maxImportErrors {
+			report.Errors = append(report.Errors, fmt.Sprintf("line %d: %s", line, msg))
+		}
+	}
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			invalid(line, parseErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "kind") {
+			continue
+		}
+		if len(record) != 4 {
+			invalid(line, "expected kind,artist,target,alias")
+			continue
+		}
+
+		kind := strings.ToLower(strings.TrimSpace(record[0]))
+		key, ok := aliasKeyFor(kind, record[1], record[2])
+		if !ok {
+			invalid(line, "unknown kind or empty artist/target")
+			continue
+		}
+		alias, ok := normalizeAlias(key, record[3])
+		if !ok {
+			invalid(line, "empty or redundant alias")
+			continue
+		}
+
+		inserted, err := insertAlias(tx.Exec, key, alias, aliasSourceImport)
+		if err != nil {
+			return report, err
+		}
+		if !inserted {
+			report.Duplicates++
+			continue
+		}
+		report.Imported++
+		added = append(added, pending{key, alias})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+	for _, p := range added {
+		storeAlias(p.key, p.alias)
+	}
+	return report, nil
+}
+
+func requireAliasAdmin(w http.ResponseWriter, r *http.Request) bool {
+	user := currentUser(r)
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if !isBlindTestAdmin(user.ID) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func handleAliases(w http.ResponseWriter, r *http.Request) {
+	if !requireAliasAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		entries, err := fetchAliases(query.Get("kind"), normalizeAnswer(cleanTarget(query.Get("artist"))))
+		if err != nil {
+			log.Println("BlindTest: aliases:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		hits, unused := 0, 0
+		for _, e := range entries {
+			hits += e.Hits
+			if e.Hits == 0 {
+				unused++
+			}
+		}
+		respondJSON(w, map[string]interface{}{
+			"aliases": entries,
+			"total":   len(entries),
+			"hits":    hits,
+			"unused":  unused,
+		})
+
+	case http.MethodPost:
+		var req aliasRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		id, err := createAlias(req)
+		if errors.Is(err, errAliasInvalid) {
+			http.Error(w, "invalid alias", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Println("BlindTest: create alias:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		respondJSONStatus(w, http.StatusCreated, map[string]interface{}{"id": id})
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "alias id required", http.StatusBadRequest)
+			return
+		}
+		err = deleteAlias(id)
+		if errors.Is(err, errAliasNotFound) {
+			http.Error(w, "alias not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println("BlindTest: delete alias:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleAliasImport(w http.ResponseWriter, r *http.Request) {
+	if !requireAliasAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, err := importAliases(http.MaxBytesReader(w, r.Body, maxAliasImportSize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		log.Println("BlindTest: import aliases:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, report)
+}
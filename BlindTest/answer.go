@@ -2,91 +2,140 @@ package blindtest
 
 import "time"
 
+const (
+	defaultMinCoverage = 0.6
+	defaultMaxGuesses  = 10
+)
+
 func handleAnswer(room *Room, player *Player, answer string) {
-	room.mu.Lock()
-	defer room.mu.Unlock()
+	if _, ok := room.Players[player.ID]; !ok {
+		return
+	}
 
 	if room.CorrectAnswers[player.ID] {
 		return
 	}
 
-	if !room.GameStarted || room.CurrentTrack == nil {
+	if room.Phase != phasePlaying || room.CurrentTrack == nil || room.Paused {
 		return
 	}
 
-	normalizedAnswer := normalizeString(answer)
-	normalizedTitle := normalizeString(room.CurrentTrack.Title)
-	normalizedArtist := normalizeString(room.CurrentTrack.Artist)
+	if room.PlayerAnswers[player.ID] == nil {
+		room.PlayerAnswers[player.ID] = &PlayerAnswer{}
+	}
 
-	foundTitle := contains(normalizedAnswer, normalizedTitle) || contains(normalizedTitle, normalizedAnswer)
-	foundArtist := contains(normalizedAnswer, normalizedArtist) || contains(normalizedArtist, normalizedAnswer)
+	playerAnswer := room.PlayerAnswers[player.ID]
 
-	if !foundTitle && !foundArtist {
+	if room.Mode == modeYear {
+		handleYearAnswer(room, player, playerAnswer, answer)
 		return
 	}
 
-	if room.PlayerAnswers[player.ID] == nil {
-		room.PlayerAnswers[player.ID] = &PlayerAnswer{}
+	if room.Mode == modeChoice {
+		handleChoiceAnswer(room, player, playerAnswer, answer)
+		return
 	}
 
-	playerAnswer := room.PlayerAnswers[player.ID]
+	if playerAnswer.Guesses >= room.MaxGuesses {
+		sendWrongAnswer(player, answer, 0)
+		return
+	}
+	playerAnswer.Guesses++
+
+	result := matchAnswer(answer, room.CurrentTrack, room.MinCoverage)
+
+	var found bool
+	switch room.Mode {
+	case modeTitle:
+		found = result.Title
+	case modeArtist:
+		found = result.Artist
+	case modeAlbum:
+		found = result.Album
+	default:
+		found = result.Title || result.Artist
+	}
+
+	if !found {
+		rejectGuess(player, playerAnswer, answer, room.MaxGuesses-playerAnswer.Guesses)
+		return
+	}
+	recordAliasHits(room.CurrentTrack, result, room.Mode)
+
+	if room.Mode == modeClassic {
+		handleClassicAnswer(room, player, playerAnswer, result.Title, result.Artist, time.Now())
+		return
+	}
+
+	handleSingleAnswer(room, player, playerAnswer, time.Now())
+}
+
+func handleClassicAnswer(room *Room, player *Player, playerAnswer *PlayerAnswer, foundTitle, foundArtist bool, at time.Time) {
 	hadBothBefore := playerAnswer.FoundTitle && playerAnswer.FoundArtist
+	newTitle := foundTitle && !playerAnswer.FoundTitle
+	newArtist := foundArtist && !playerAnswer.FoundArtist
 
-	if foundTitle && !playerAnswer.FoundTitle {
+	if !newTitle && !newArtist {
+		msg := wrongAnswerMessage("", room.MaxGuesses-playerAnswer.Guesses)
+		msg.Data["duplicate"] = true
+		player.Client.Send(msg)
+		return
+	}
+
+	if newTitle {
 		playerAnswer.FoundTitle = true
-		playerAnswer.TimeTitle = time.Now()
+		playerAnswer.TimeTitle = at
 	}
 
-	if foundArtist && !playerAnswer.FoundArtist {
+	if newArtist {
 		playerAnswer.FoundArtist = true
-		playerAnswer.TimeArtist = time.Now()
+		playerAnswer.TimeArtist = at
 	}
 
 	hasBothNow := playerAnswer.FoundTitle && playerAnswer.FoundArtist
 
 	var points int
+	var breakdown pointsBreakdown
 	var color string
 	var answerType string
 
 	if hasBothNow && !hadBothBefore {
 		room.CorrectAnswers[player.ID] = true
 
-		var elapsed float64
-		if foundTitle && foundArtist {
-			elapsed = time.Since(room.RoundStartTime).Seconds()
+		if newTitle && newArtist {
 			answerType = "both"
-		} else if foundTitle {
-			elapsed = time.Since(room.RoundStartTime).Seconds()
+		} else if newTitle {
 			answerType = "title_completing"
 		} else {
-			elapsed = time.Since(room.RoundStartTime).Seconds()
 			answerType = "artist_completing"
 		}
 
-		points = calculatePoints(elapsed)
-		player.Score += points
+		breakdown = scoreAnswer(room, player, false, at)
+		points = breakdown.Total
+		awardPoints(room, player, points)
 		color = "green"
 
-		for _, p := range room.Players {
-			p.Conn.WriteJSON(Message{
-				Type: "correct_answer",
-				Data: map[string]interface{}{
-					"username":    player.Username,
-					"points":      points,
-					"answerType":  answerType,
-					"color":       color,
-					"foundTitle":  playerAnswer.FoundTitle,
-					"foundArtist": playerAnswer.FoundArtist,
-				},
-			})
-		}
-	} else if !hadBothBefore {
-		elapsed := time.Since(room.RoundStartTime).Seconds()
-		points = calculatePoints(elapsed) / 2
-
-		player.Score += points
-
-		if foundTitle {
+		broadcast(room, Message{
+			Type: "correct_answer",
+			Data: map[string]interface{}{
+				"username":    player.Username,
+				"points":      points,
+				"breakdown":   breakdown,
+				"answerType":  answerType,
+				"color":       color,
+				"foundTitle":  playerAnswer.FoundTitle,
+				"foundArtist": playerAnswer.FoundArtist,
+				"team":        player.Team,
+				"teams":       teamStandings(room),
+			},
+		})
+	} else {
+		breakdown = scoreAnswer(room, player, true, at)
+		points = breakdown.Total
+
+		awardPoints(room, player, points)
+
+		if newTitle {
 			answerType = "title_partial"
 			color = "orange"
 		} else {
@@ -94,57 +143,43 @@ func handleAnswer(room *Room, player *Player, answer string) {
 			color = "orange"
 		}
 
-		for _, p := range room.Players {
-			p.Conn.WriteJSON(Message{
-				Type: "correct_answer",
-				Data: map[string]interface{}{
-					"username":    player.Username,
-					"points":      points,
-					"answerType":  answerType,
-					"color":       color,
-					"foundTitle":  playerAnswer.FoundTitle,
-					"foundArtist": playerAnswer.FoundArtist,
-				},
-			})
-		}
+		broadcast(room, Message{
+			Type: "correct_answer",
+			Data: map[string]interface{}{
+				"username":    player.Username,
+				"points":      points,
+				"breakdown":   breakdown,
+				"answerType":  answerType,
+				"color":       color,
+				"foundTitle":  playerAnswer.FoundTitle,
+				"foundArtist": playerAnswer.FoundArtist,
+				"team":        player.Team,
+				"teams":       teamStandings(room),
+			},
+		})
 	}
 }
 
-func normalizeString(s string) string {
-	result := ""
-	for _, r := range s {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
-			if r >= 'A' && r <= 'Z' {
-				result += string(r + 32)
-			} else {
-				result += string(r)
-			}
-		}
+func wrongAnswerMessage(answer string, remaining int) Message {
+	return Message{
+		Type: "wrong_answer",
+		Data: map[string]interface{}{
+			"answer":           answer,
+			"remainingGuesses": remaining,
+		},
 	}
-	return result
 }
 
-func contains(s, substr string) bool {
-	return len(s) > 0 && len(substr) > 0 && len(s) >= len(substr) && findSubstring(s, substr)
+func sendWrongAnswer(player *Player, answer string, remaining int) {
+	player.Client.Send(wrongAnswerMessage(answer, remaining))
 }
 
-func findSubstring(s, substr string) bool {
-	if len(substr) > len(s) {
-		return false
-	}
-	for i := 0; i <= len(s)-len(substr); i++ {
-		match := true
-		for j := 0; j < len(substr); j++ {
-			if s[i+j] != substr[j] {
-				match = false
-				break
-			}
-		}
-		if match {
-			return true
-		}
-	}
-	return false
+func rejectGuess(player *Player, playerAnswer *PlayerAnswer, answer string, remaining int) {
+	playerAnswer.Rejected = append(playerAnswer.Rejected, RejectedGuess{Text: answer, At: time.Now()})
+
+	msg := wrongAnswerMessage(answer, remaining)
+	msg.Data["guessId"] = len(playerAnswer.Rejected) - 1
+	player.Client.Send(msg)
 }
 
 func calculatePoints(elapsed float64) int {
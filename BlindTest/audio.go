@@ -0,0 +1,173 @@
+package blindtest
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	audioPath         = "/blindtest/audio/"
+	audioTokenGrace   = 10 * time.Second
+	audioCacheLimit   = 200
+	audioFetchTimeout = 15 * time.Second
+)
+
+type audioToken struct {
+	track   Track
+	expires time.Time
+}
+
+var (
+	audioTokensMu sync.Mutex
+	audioTokens   = make(map[string]audioToken)
+)
+
+func issueAudioToken(track Track, ttl time.Duration) string {
+	token := generateReconnectToken()
+
+	audioTokensMu.Lock()
+	defer audioTokensMu.Unlock()
+
+	now := time.Now()
+	for t, entry := range audioTokens {
+		if now.After(entry.expires) {
+			delete(audioTokens, t)
+		}
+	}
+	audioTokens[token] = audioToken{track: track, expires: now.Add(ttl)}
+	return token
+}
+
+func revokeAudioToken(token string) {
+	if token == "" {
+		return
+	}
+	audioTokensMu.Lock()
+	delete(audioTokens, token)
+	audioTokensMu.Unlock()
+}
+
+func extendAudioToken(token string, ttl time.Duration) {
+	audioTokensMu.Lock()
+	defer audioTokensMu.Unlock()
+
+	if entry, ok := audioTokens[token]; ok {
+		entry.expires = time.Now().Add(ttl)
+		audioTokens[token] = entry
+	}
+}
+
+func lookupAudioToken(token string) (Track, bool) {
+	audioTokensMu.Lock()
+	defer audioTokensMu.Unlock()
+
+	entry, ok := audioTokens[token]
+	if !ok || time.Now().After(entry.expires) {
+		return Track{}, false
+	}
+	return entry.track, true
+}
+
+func audioURL(token string) string {
+	return audioPath + token
+}
+
+func roundAudioTTL(room *Room) time.Duration {
+	return time.Duration(room.RoundTime)*time.Second + playbackLead + audioTokenGrace
+}
+
+func issueRoundAudio(room *Room) {
+	revokeAudioToken(room.AudioToken)
+	room.AudioToken = issueAudioToken(*room.CurrentTrack, roundAudioTTL(room))
+
+	go prefetchAudio(*room.CurrentTrack)
+	if next := room.CurrentTrackIdx + 1; next < len(room.Tracks) && next < room.MaxRounds {
+		go prefetchAudio(room.Tracks[next])
+	}
+}
+
+var previews = newPreviewCache(os.TempDir())
+
+func newPreviewCache(root string) *fileCache {
+	return newFileCache(filepath.Join(root, "blindtest-audio"), ".mp3", audioCacheLimit, audioFetchTimeout, isMP3)
+}
+
+func audioKey(track Track) string {
+	if track.ID != 0 {
+		return strconv.FormatInt(track.ID, 10)
+	}
+	sum := sha1.Sum([]byte(track.Preview))
+	return hex.EncodeToString(sum[:])
+}
+
+func openPreview(ctx context.Context, track Track) (*os.File, error) {
+	return previews.open(ctx, audioKey(track), func(ctx context.Context, path string) error {
+		err := previews.download(ctx, track.Preview, path)
+		if !errors.Is(err, errRemoteGone) || track.ID == 0 {
+			return err
+		}
+
+		fresh, err := deezer.track(ctx, track.ID)
+		if err != nil {
+			return fmt.Errorf("refresh preview: %w", err)
+		}
+		return previews.download(ctx, fresh.Preview, path)
+	})
+}
+
+func prefetchAudio(track Track) {
+	ctx, cancel := context.WithTimeout(context.Background(), audioFetchTimeout)
+	defer cancel()
+
+	f, err := openPreview(ctx, track)
+	if err != nil {
+		log.Println("BlindTest: prefetch preview:", err)
+		return
+	}
+	f.Close()
+}
+
+func handleAudio(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	track, ok := lookupAudioToken(strings.TrimPrefix(r.URL.Path, audioPath))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), audioFetchTimeout)
+	defer cancel()
+
+	f, err := openPreview(ctx, track)
+	if err != nil {
+		log.Println("BlindTest: preview:", err)
+		http.Error(w, "audio unavailable", http.StatusBadGateway)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "audio unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
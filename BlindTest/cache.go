@@ -0,0 +1,234 @@
+package blindtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cacheHeaderSize = 16
+
+var (
+	errRemoteGone  = errors.New("remote file gone")
+	errInvalidFile = errors.New("unexpected file content")
+)
+
+type fileCache struct {
+	dir        string
+	ext        string
+	limit      int
+	valid      func(header []byte) bool
+	httpClient *http.Client
+
+	indexOnce sync.Once
+	mu        sync.Mutex
+	used      map[string]time.Time
+	locks     map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newFileCache(dir, ext string, limit int, timeout time.Duration, valid func([]byte) bool) *fileCache {
+	return &fileCache{
+		dir:        dir,
+		ext:        ext,
+		limit:      limit,
+		valid:      valid,
+		httpClient: &http.Client{Timeout: timeout},
+		used:       make(map[string]time.Time),
+		locks:      make(map[string]*keyLock),
+	}
+}
+
+func (c *fileCache) path(key string) string {
+	return filepath.Join(c.dir, key+c.ext)
+}
+
+func (c *fileCache) index() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "download-") {
+			os.Remove(filepath.Join(c.dir, name))
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || !strings.HasSuffix(name, c.ext) {
+			continue
+		}
+		key := strings.TrimSuffix(name, c.ext)
+		if _, ok := c.used[key]; !ok {
+			c.used[key] = info.ModTime()
+		}
+	}
+	c.evict()
+}
+
+func (c *fileCache) warm() {
+	c.indexOnce.Do(c.index)
+}
+
+func (c *fileCache) lock(key string) *keyLock {
+	c.mu.Lock()
+	l, ok := c.locks[key]
+	if !ok {
+		l = &keyLock{}
+		c.locks[key] = l
+	}
+	l.refs++
+	c.mu.Unlock()
+
+	l.Lock()
+	return l
+}
+
+func (c *fileCache) unlock(key string, l *keyLock) {
+	l.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(c.locks, key)
+	}
+}
+
+func (c *fileCache) touch(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.used[key] = time.Now()
+	c.evict()
+}
+
+// evict must be called with c.mu held. Files being filled or opened are kept.
+func (c *fileCache) evict() {
+	for len(c.used) > c.limit {
+		oldest := ""
+		for k, at := range c.used {
+			if _, busy := c.locks[k]; busy {
+				continue
+			}
+			if oldest == "" || at.Before(c.used[oldest]) {
+				oldest = k
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		delete(c.used, oldest)
+		os.Remove(c.path(oldest))
+	}
+}
+
+func (c *fileCache) openValid(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, cacheHeaderSize)
+	n, _ := io.ReadFull(f, header)
+	if c.valid != nil && !c.valid(header[:n]) {
+		f.Close()
+		os.Remove(path)
+		return nil, errInvalidFile
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (c *fileCache) open(ctx context.Context, key string, fill func(context.Context, string) error) (*os.File, error) {
+	c.warm()
+
+	l := c.lock(key)
+	defer c.unlock(key, l)
+
+	path := c.path(key)
+	if f, err := c.openValid(path); err == nil {
+		c.touch(key)
+		return f, nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := fill(ctx, path); err != nil {
+		return nil, err
+	}
+	c.touch(key)
+	return c.openValid(path)
+}
+
+func (c *fileCache) download(ctx context.Context, source, path string) error {
+	if source == "" {
+		return errRemoteGone
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		return errRemoteGone
+	default:
+		return fmt.Errorf("download: unexpected status %d", resp.StatusCode)
+	}
+
+	header := make([]byte, cacheHeaderSize)
+	n, _ := io.ReadFull(resp.Body, header)
+	if c.valid != nil && !c.valid(header[:n]) {
+		return fmt.Errorf("download %s: %w", c.ext, errInvalidFile)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, "download-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, io.MultiReader(bytes.NewReader(header[:n]), resp.Body)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func isMP3(header []byte) bool {
+	return bytes.HasPrefix(header, []byte("ID3")) ||
+		(len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0)
+}
+
+func isJPEG(header []byte) bool {
+	return bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF})
+}
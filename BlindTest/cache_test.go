@@ -0,0 +1,90 @@
+package blindtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCacheFile(t *testing.T, dir, name, content string, age time.Duration) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Now().Add(-age)
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileCacheIndexesExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeCacheFile(t, dir, "1.mp3", "ID3 old", 3*time.Hour)
+	writeCacheFile(t, dir, "2.mp3", "ID3 newer", 2*time.Hour)
+	writeCacheFile(t, dir, "3.mp3", "ID3 newest", time.Hour)
+	writeCacheFile(t, dir, "download-123", "partial", time.Hour)
+
+	cache := newFileCache(dir, ".mp3", 2, time.Second, isMP3)
+	f, err := cache.open(context.Background(), "3", func(context.Context, string) error {
+		t.Fatal("cached file should not be refilled")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for name, want := range map[string]bool{"1.mp3": false, "2.mp3": true, "3.mp3": true, "download-123": false} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists != want {
+			t.Errorf("%s exists = %v, want %v", name, exists, want)
+		}
+	}
+}
+
+func TestFileCacheRejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeCacheFile(t, dir, "1.mp3", "stub bytes", 0)
+
+	cache := newFileCache(dir, ".mp3", 10, time.Second, isMP3)
+	filled := false
+	f, err := cache.open(context.Background(), "1", func(_ context.Context, path string) error {
+		filled = true
+		return os.WriteFile(path, []byte("ID3 real audio"), 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if !filled {
+		t.Error("invalid cached file was served instead of refilled")
+	}
+
+	_, err = cache.open(context.Background(), "2", func(_ context.Context, path string) error {
+		return os.WriteFile(path, []byte("<html>"), 0o644)
+	})
+	if err == nil {
+		t.Error("invalid download was accepted")
+	}
+}
+
+func TestFileCacheReleasesLocks(t *testing.T) {
+	cache := newFileCache(t.TempDir(), ".jpg", 1, time.Second, isJPEG)
+	for _, key := range []string{"1", "2", "3"} {
+		f, err := cache.open(context.Background(), key, func(_ context.Context, path string) error {
+			return os.WriteFile(path, []byte("\xff\xd8\xff"), 0o644)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if len(cache.locks) != 0 || len(cache.used) != 1 {
+		t.Errorf("locks = %d, used = %d; want 0 and 1", len(cache.locks), len(cache.used))
+	}
+}
@@ -0,0 +1,161 @@
+package blindtest
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	choiceCount       = 4
+	choiceWrongPoints = -200
+	choiceMinPoints   = 100
+	choiceMaxPoints   = 1000
+	choicePoolFactor  = 4
+)
+
+type ChoiceOption struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+}
+
+func buildChoices(room *Room) {
+	current := room.CurrentTrack
+	upcoming := room.Tracks[min(room.CurrentTrackIdx+1, len(room.Tracks)):]
+	pool := make([]Track, 0, len(room.ChoicePool))
+	for _, t := range room.ChoicePool {
+		if sameSong(t, *current) {
+			continue
+		}
+		duplicate := false
+		for _, next := range upcoming {
+			if sameSong(t, next) {
+				duplicate = true
+				break
+			}
+		}
+		for _, picked := range pool {
+			if sameSong(t, picked) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			pool = append(pool, t)
+		}
+	}
+	rand.Shuffle(len(pool), func(i, j int) {
+		pool[i], pool[j] = pool[j], pool[i]
+	})
+	if len(pool) > choiceCount-1 {
+		pool = pool[:choiceCount-1]
+	}
+
+	tracks := append([]Track{*current}, pool...)
+	rand.Shuffle(len(tracks), func(i, j int) {
+		tracks[i], tracks[j] = tracks[j], tracks[i]
+	})
+
+	room.Options = make([]ChoiceOption, 0, len(tracks))
+	for i, t := range tracks {
+		option := ChoiceOption{
+			ID:     strconv.Itoa(i + 1),
+			Title:  t.Title,
+			Artist: t.Artist,
+		}
+		if sameSong(t, *current) {
+			room.CorrectOption = option.ID
+		}
+		room.Options = append(room.Options, option)
+	}
+}
+
+func sameSong(a, b Track) bool {
+	if a.ID != 0 && a.ID == b.ID {
+		return true
+	}
+	return strings.EqualFold(a.Title, b.Title) && strings.EqualFold(a.Artist, b.Artist)
+}
+
+func handleChoiceAnswer(room *Room, player *Player, playerAnswer *PlayerAnswer, optionID string) {
+	if playerAnswer.Choice != "" {
+		return
+	}
+
+	valid := false
+	for _, option := range room.Options {
+		if option.ID == optionID {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return
+	}
+
+	playerAnswer.Choice = optionID
+	playerAnswer.TimeChoice = time.Now()
+	playerAnswer.Guesses++
+
+	player.Client.Send(Message{
+		Type: "guess_received",
+		Data: map[string]interface{}{
+			"optionId": optionID,
+		},
+	})
+
+	broadcast(room, Message{
+		Type: "player_guessed",
+		Data: map[string]interface{}{
+			"username": player.Username,
+		},
+	})
+}
+
+func scoreChoices(room *Room) []map[string]interface{} {
+	results := make([]map[string]interface{}, 0)
+
+	for id, p := range room.Players {
+		playerAnswer := room.PlayerAnswers[id]
+		if playerAnswer == nil || playerAnswer.Choice == "" {
+			continue
+		}
+
+		correct := playerAnswer.Choice == room.CorrectOption
+		points := choiceWrongPoints
+		if correct {
+			elapsed := listeningElapsed(room, p, playerAnswer.TimeChoice).Seconds()
+			points = choicePoints(elapsed, float64(room.RoundTime))
+			room.CorrectAnswers[id] = true
+		}
+		breakdown := applyStreak(room, p, pointsBreakdown{Base: points, Ratio: 1}, float64(points))
+		points = breakdown.Total
+		awardPoints(room, p, points)
+
+		results = append(results, map[string]interface{}{
+			"username":  p.Username,
+			"optionId":  playerAnswer.Choice,
+			"correct":   correct,
+			"points":    points,
+			"breakdown": breakdown,
+		})
+	}
+
+	return results
+}
+
+func choicePoints(elapsed, roundTime float64) int {
+	if roundTime <= 0 || elapsed >= roundTime {
+		return choiceMinPoints
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	points := choiceMaxPoints - int(float64(choiceMaxPoints-choiceMinPoints)*elapsed/roundTime)
+	if points < choiceMinPoints {
+		return choiceMinPoints
+	}
+	return points
+}
@@ -0,0 +1,68 @@
+package blindtest
+
+import "time"
+
+const (
+	playbackLead     = 1500 * time.Millisecond
+	maxPlaybackDelay = 2 * time.Second
+)
+
+type playbackCommand struct {
+	player    *Player
+	startedAt int64
+	received  time.Time
+}
+
+func serverMillis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+func clockSyncReply(clientTime int64) Message {
+	return Message{
+		Type: "clock_sync",
+		Data: map[string]interface{}{
+			"clientTime": clientTime,
+			"serverTime": serverMillis(time.Now()),
+		},
+	}
+}
+
+func recordPlayback(room *Room, player *Player, startedAt int64, received time.Time) {
+	if room.Phase != phasePlaying || room.Players[player.ID] != player {
+		return
+	}
+	if _, ok := room.PlaybackStarts[player.ID]; ok {
+		return
+	}
+
+	start := time.UnixMilli(startedAt)
+	latest := room.RoundStartTime.Add(maxPlaybackDelay)
+	if received.Before(latest) {
+		latest = received
+	}
+	if start.After(latest) {
+		start = latest
+	}
+	if start.Before(room.RoundStartTime) {
+		start = room.RoundStartTime
+	}
+	room.PlaybackStarts[player.ID] = start
+}
+
+func listeningElapsed(room *Room, player *Player, at time.Time) time.Duration {
+	start := room.RoundStartTime
+	if playback, ok := room.PlaybackStarts[player.ID]; ok {
+		start = playback
+	}
+	elapsed := at.Sub(start)
+	if elapsed < 0 {
+		return 0
+	}
+	return elapsed
+}
+
+func shiftPlaybackStarts(room *Room, d time.Duration) {
+	for id, start := range room.PlaybackStarts {
+		room.PlaybackStarts[id] = start.Add(d)
+	}
+}
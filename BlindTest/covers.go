@@ -0,0 +1,121 @@
+package blindtest
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	coverPath         = "/blindtest/cover/"
+	coverCacheLimit   = 500
+	coverFetchTimeout = 10 * time.Second
+)
+
+var covers = newCoverCache(os.TempDir())
+
+func newCoverCache(root string) *fileCache {
+	return newFileCache(filepath.Join(root, "blindtest-covers"), ".jpg", coverCacheLimit, coverFetchTimeout, isJPEG)
+}
+
+var (
+	revealedMu     sync.Mutex
+	revealedAlbums = make(map[int64]bool)
+)
+
+func revealAlbum(albumID int64) {
+	if albumID == 0 {
+		return
+	}
+	revealedMu.Lock()
+	revealedAlbums[albumID] = true
+	revealedMu.Unlock()
+}
+
+func albumRevealed(albumID int64) bool {
+	revealedMu.Lock()
+	revealed := revealedAlbums[albumID]
+	revealedMu.Unlock()
+	if revealed || btDB == nil {
+		return revealed
+	}
+
+	var exists int
+	if err := btDB.QueryRow(`SELECT 1 FROM blindtest_rounds WHERE album_id = ? LIMIT 1`, albumID).Scan(&exists); err != nil {
+		return false
+	}
+	revealAlbum(albumID)
+	return true
+}
+
+func coverURL(albumID int64) string {
+	if albumID == 0 {
+		return ""
+	}
+	return coverPath + strconv.FormatInt(albumID, 10)
+}
+
+func openCover(ctx context.Context, albumID int64) (*os.File, error) {
+	return covers.open(ctx, strconv.FormatInt(albumID, 10), func(ctx context.Context, path string) error {
+		source, err := deezer.albumCover(ctx, albumID)
+		if err != nil {
+			return err
+		}
+		return covers.download(ctx, source, path)
+	})
+}
+
+func prefetchCover(albumID int64) {
+	if albumID == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), coverFetchTimeout)
+	defer cancel()
+
+	f, err := openCover(ctx, albumID)
+	if err != nil {
+		log.Println("BlindTest: prefetch cover:", err)
+		return
+	}
+	f.Close()
+}
+
+func handleCover(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	albumID, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, coverPath), 10, 64)
+	if err != nil || albumID <= 0 || !albumRevealed(albumID) {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), coverFetchTimeout)
+	defer cancel()
+
+	f, err := openCover(ctx, albumID)
+	if err != nil {
+		log.Println("BlindTest: cover:", err)
+		http.Error(w, "cover unavailable", http.StatusBadGateway)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "cover unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
@@ -1,230 +1,483 @@
 package blindtest
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
 )
 
-func fetchTracksFromDeezer(playlist string, limit int) ([]Track, error) {
-	if playlist == "generale" {
-		return fetchMixedGenreTracks(limit)
+const (
+	defaultDeezerBaseURL = "https://api.deezer.com"
+	deezerQuotaErrorCode = 4
+)
+
+var deezer = newDeezerClient(defaultDeezerBaseURL)
+
+type deezerClient struct {
+	BaseURL     string
+	HTTPClient  *http.Client
+	MaxRetries  int
+	Concurrency int
+	RetryDelay  time.Duration
+	limiter     *rateLimiter
+}
+
+type deezerError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+func (e *deezerError) Error() string {
+	return fmt.Sprintf("deezer: %s (code %d)", e.Message, e.Code)
+}
+
+func (e *deezerError) isQuota() bool {
+	return e.Code == deezerQuotaErrorCode
+}
+
+type deezerStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *deezerStatusError) Error() string {
+	return fmt.Sprintf("deezer: unexpected status %d", e.StatusCode)
+}
+
+type deezerTrack struct {
+	ID          int64   `json:"id"`
+	Title       string  `json:"title"`
+	Preview     string  `json:"preview"`
+	Duration    int     `json:"duration"`
+	Rank        int     `json:"rank"`
+	ReleaseDate string  `json:"release_date"`
+	Link        string  `json:"link"`
+	Explicit    bool    `json:"explicit_lyrics"`
+	BPM         float64 `json:"bpm"`
+	Artist      struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	} `json:"artist"`
+	Album struct {
+		ID    int64  `json:"id"`
+		Title string `json:"title"`
+	} `json:"album"`
+}
+
+type deezerTrackList struct {
+	Data []deezerTrack `json:"data"`
+}
+
+type deezerPlaylistList struct {
+	Data []struct {
+		ID       int64 `json:"id"`
+		NbTracks int   `json:"nb_tracks"`
+	} `json:"data"`
+}
+
+func newDeezerClient(baseURL string) *deezerClient {
+	return &deezerClient{
+		BaseURL:     baseURL,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+		MaxRetries:  3,
+		Concurrency: 5,
+		RetryDelay:  300 * time.Millisecond,
+		limiter:     newRateLimiter(50, 5*time.Second),
+	}
+}
+
+func (c *deezerClient) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	endpoint := c.BaseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
 	}
 
-	if playlist == "francaise" {
-		return fetchFrenchTracks(limit)
+	var lastErr error
+	for attempt := 0; attempt <= c.MaxRetries; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, c.backoff(attempt, lastErr)); err != nil {
+				return err
+			}
+		}
+
+		err := c.do(ctx, endpoint, out)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !isRetryable(err) || ctx.Err() != nil {
+			return err
+		}
 	}
+	return lastErr
+}
 
-	genreID := getGenreID(playlist)
+func (c *deezerClient) do(ctx context.Context, endpoint string, out interface{}) error {
+	if err := c.limiter.wait(ctx); err != nil {
+		return err
+	}
 
-	url := fmt.Sprintf("https://api.deezer.com/chart/%d/tracks?limit=100", genreID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
 
-	resp, err := http.Get(url)
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &deezerStatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: time.Duration(retryAfter) * time.Second,
+		}
+	}
+
 	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var envelope struct {
+		Error *deezerError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error != nil {
+		return envelope.Error
+	}
+
+	return json.Unmarshal(body, out)
+}
+
+func (c *deezerClient) backoff(attempt int, lastErr error) time.Duration {
+	var statusErr *deezerStatusError
+	if errors.As(lastErr, &statusErr) && statusErr.RetryAfter > 0 {
+		return statusErr.RetryAfter
+	}
+	delay := c.RetryDelay << (attempt - 1)
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)+1))
+}
+
+func isRetryable(err error) bool {
+	var apiErr *deezerError
+	if errors.As(err, &apiErr) {
+		return apiErr.isQuota()
+	}
+	var statusErr *deezerStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func (c *deezerClient) chartTracks(ctx context.Context, genreID, limit int) ([]Track, error) {
+	var result deezerTrackList
+	path := fmt.Sprintf("/chart/%d/tracks", genreID)
+	err := c.get(ctx, path, url.Values{"limit": {strconv.Itoa(limit)}}, &result)
 	if err != nil {
 		return nil, err
 	}
+	tracks := result.tracks()
+	for i := range tracks {
+		tracks[i].ChartPosition = i + 1
+	}
+	return tracks, nil
+}
 
-	var result struct {
-		Data []struct {
-			Title   string `json:"title"`
-			Preview string `json:"preview"`
-			Artist  struct {
-				Name string `json:"name"`
-			} `json:"artist"`
-			Album struct {
-				Title string `json:"title"`
-			} `json:"album"`
-			Duration int `json:"duration"`
-		} `json:"data"`
+func (c *deezerClient) searchTracks(ctx context.Context, query string, limit int) ([]Track, error) {
+	var result deezerTrackList
+	err := c.get(ctx, "/search", url.Values{"q": {query}, "limit": {strconv.Itoa(limit)}}, &result)
+	if err != nil {
+		return nil, err
 	}
+	return result.tracks(), nil
+}
 
-	err = json.Unmarshal(body, &result)
+func (c *deezerClient) playlistTracks(ctx context.Context, playlistID int64, limit int) ([]Track, error) {
+	var result deezerTrackList
+	path := fmt.Sprintf("/playlist/%d/tracks", playlistID)
+	err := c.get(ctx, path, url.Values{"limit": {strconv.Itoa(limit)}}, &result)
 	if err != nil {
 		return nil, err
 	}
+	return result.tracks(), nil
+}
+
+func (c *deezerClient) searchPlaylists(ctx context.Context, query string, limit int) ([]int64, error) {
+	var result deezerPlaylistList
+	err := c.get(ctx, "/search/playlist", url.Values{"q": {query}, "limit": {strconv.Itoa(limit)}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(result.Data))
+	for _, p := range result.Data {
+		if p.NbTracks > 0 {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids, nil
+}
 
-	tracks := make([]Track, 0)
-	for _, item := range result.Data {
+func (l deezerTrackList) tracks() []Track {
+	tracks := make([]Track, 0, len(l.Data))
+	for _, item := range l.Data {
 		if item.Preview != "" {
-			tracks = append(tracks, Track{
-				Title:    item.Title,
-				Artist:   item.Artist.Name,
-				Preview:  item.Preview,
-				Album:    item.Album.Title,
-				Duration: item.Duration,
-			})
+			tracks = append(tracks, item.toTrack())
 		}
 	}
+	return tracks
+}
 
-	rand.Shuffle(len(tracks), func(i, j int) {
-		tracks[i], tracks[j] = tracks[j], tracks[i]
-	})
+func (t deezerTrack) toTrack() Track {
+	return Track{
+		ID:          t.ID,
+		Title:       t.Title,
+		Artist:      t.Artist.Name,
+		Preview:     t.Preview,
+		Album:       t.Album.Title,
+		AlbumID:     t.Album.ID,
+		Duration:    t.Duration,
+		ReleaseDate: t.ReleaseDate,
+		Rank:        t.Rank,
+		Link:        t.Link,
+		Explicit:    t.Explicit,
+		BPM:         t.BPM,
+	}
+}
 
-	if len(tracks) > limit {
-		tracks = tracks[:limit]
+func (c *deezerClient) parallel(ctx context.Context, count int, fn func(context.Context, int) error) []error {
+	workers := c.Concurrency
+	if workers <= 0 {
+		workers = 1
 	}
+	sem := make(chan struct{}, workers)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				mu.Lock()
+				errs = append(errs, ctx.Err())
+				mu.Unlock()
+				return
+			}
+			defer func() { <-sem }()
 
-	return tracks, nil
-}
+			if err := fn(ctx, i); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
 
-func fetchMixedGenreTracks(limit int) ([]Track, error) {
-	allGenres := []string{"pop", "rock", "rap", "electronic", "indie", "classic", "country", "jazz", "blues", "reggae", "rnb", "soul", "metal", "alternative", "techno"}
+	return errs
+}
 
-	tracksPerGenre := 10
-	allTracks := make([]Track, 0)
+func (c *deezerClient) fanOut(ctx context.Context, keys []string, fetch func(context.Context, string) ([]Track, error)) ([]Track, error) {
+	var (
+		mu        sync.Mutex
+		allTracks []Track
+	)
 
-	for _, genre := range allGenres {
-		tracks, err := fetchTracksFromGenre(genre, tracksPerGenre)
-		if err == nil {
-			allTracks = append(allTracks, tracks...)
+	errs := c.parallel(ctx, len(keys), func(ctx context.Context, i int) error {
+		tracks, err := fetch(ctx, keys[i])
+		if err != nil {
+			return fmt.Errorf("%s: %w", keys[i], err)
 		}
-	}
-
-	rand.Shuffle(len(allTracks), func(i, j int) {
-		allTracks[i], allTracks[j] = allTracks[j], allTracks[i]
+		mu.Lock()
+		allTracks = append(allTracks, tracks...)
+		mu.Unlock()
+		return nil
 	})
 
-	if len(allTracks) > limit {
-		allTracks = allTracks[:limit]
+	if len(errs) > 0 {
+		log.Printf("Deezer: %d/%d requests failed: %v", len(errs), len(keys), errors.Join(errs...))
+	}
+	if len(allTracks) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
-
 	return allTracks, nil
 }
 
-func fetchFrenchTracks(limit int) ([]Track, error) {
-	frenchArtists := []string{
-		"Stromae", "Angèle", "Orelsan", "Edith Piaf", "Charles Aznavour",
-		"Indila", "Ninho", "Aya Nakamura", "Jul", "Soprano",
-		"Jacques Brel", "Dalida", "Claude François", "Johnny Hallyday",
-		"Zaz", "Céline Dion", "GIMS", "PNL", "Booba", "Nekfeu",
-		"Damso", "SCH", "Naps", "Maître Gims", "Black M",
-		"Louane", "Kendji Girac", "Vitaa", "Slimane", "Dadju",
+func (c *deezerClient) albumReleaseDate(ctx context.Context, albumID int64) (string, error) {
+	var album struct {
+		ReleaseDate string `json:"release_date"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("/album/%d", albumID), nil, &album); err != nil {
+		return "", err
 	}
+	return album.ReleaseDate, nil
+}
 
-	allTracks := make([]Track, 0)
-	tracksPerArtist := 5
+func (c *deezerClient) albumCover(ctx context.Context, albumID int64) (string, error) {
+	var album struct {
+		CoverXL  string `json:"cover_xl"`
+		CoverBig string `json:"cover_big"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("/album/%d", albumID), nil, &album); err != nil {
+		return "", err
+	}
+	if album.CoverBig != "" {
+		return album.CoverBig, nil
+	}
+	return album.CoverXL, nil
+}
 
-	for _, artist := range frenchArtists {
-		url := fmt.Sprintf("https://api.deezer.com/search?q=artist:\"%s\"&limit=%d", artist, tracksPerArtist)
+func (c *deezerClient) track(ctx context.Context, trackID int64) (Track, error) {
+	var track deezerTrack
+	if err := c.get(ctx, fmt.Sprintf("/track/%d", trackID), nil, &track); err != nil {
+		return Track{}, err
+	}
+	return track.toTrack(), nil
+}
 
-		resp, err := http.Get(url)
-		if err != nil {
-			continue
+func (c *deezerClient) fillReleaseDates(ctx context.Context, tracks []Track) {
+	errs := c.parallel(ctx, len(tracks), func(ctx context.Context, i int) error {
+		if tracks[i].ReleaseDate != "" || tracks[i].AlbumID == 0 {
+			return nil
 		}
-
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
+		date, err := c.albumReleaseDate(ctx, tracks[i].AlbumID)
 		if err != nil {
-			continue
-		}
-
-		var result struct {
-			Data []struct {
-				Title   string `json:"title"`
-				Preview string `json:"preview"`
-				Artist  struct {
-					Name string `json:"name"`
-				} `json:"artist"`
-				Album struct {
-					Title string `json:"title"`
-				} `json:"album"`
-				Duration int `json:"duration"`
-			} `json:"data"`
+			return fmt.Errorf("album %d: %w", tracks[i].AlbumID, err)
 		}
+		tracks[i].ReleaseDate = date
+		return nil
+	})
+	if len(errs) > 0 {
+		log.Printf("Deezer: %d release dates missing: %v", len(errs), errors.Join(errs...))
+	}
+}
 
-		err = json.Unmarshal(body, &result)
+func (c *deezerClient) fillTrackDetails(ctx context.Context, tracks []Track) {
+	errs := c.parallel(ctx, len(tracks), func(ctx context.Context, i int) error {
+		details, err := c.track(ctx, tracks[i].ID)
 		if err != nil {
-			continue
+			return fmt.Errorf("track %d: %w", tracks[i].ID, err)
 		}
-
-		for _, item := range result.Data {
-			if item.Preview != "" {
-				allTracks = append(allTracks, Track{
-					Title:    item.Title,
-					Artist:   item.Artist.Name,
-					Preview:  item.Preview,
-					Album:    item.Album.Title,
-					Duration: item.Duration,
-				})
-			}
+		t := &tracks[i]
+		if details.Preview != "" {
+			t.Preview = details.Preview
 		}
-
-		if len(allTracks) >= limit*2 {
-			break
+		if t.ReleaseDate == "" {
+			t.ReleaseDate = details.ReleaseDate
 		}
-	}
-
-	rand.Shuffle(len(allTracks), func(i, j int) {
-		allTracks[i], allTracks[j] = allTracks[j], allTracks[i]
+		if t.AlbumID == 0 {
+			t.AlbumID = details.AlbumID
+		}
+		t.Link = details.Link
+		t.Explicit = details.Explicit
+		t.BPM = details.BPM
+		return nil
 	})
-
-	if len(allTracks) > limit {
-		allTracks = allTracks[:limit]
+	if len(errs) > 0 {
+		log.Printf("Deezer: %d track details missing: %v", len(errs), errors.Join(errs...))
 	}
-
-	return allTracks, nil
 }
 
-func fetchTracksFromGenre(genre string, limit int) ([]Track, error) {
-	genreID := getGenreID(genre)
-	if genreID == 0 {
-		return []Track{}, nil
+func fetchTracksFromDeezer(ctx context.Context, playlist string, limit int) ([]Track, error) {
+	if playlist == "generale" {
+		return fetchMixedGenreTracks(ctx, limit)
+	}
+
+	if playlist == "francaise" {
+		return fetchFrenchTracks(ctx, limit)
 	}
 
-	url := fmt.Sprintf("https://api.deezer.com/chart/%d/tracks?limit=%d", genreID, limit)
+	genreID := getGenreID(playlist)
 
-	resp, err := http.Get(url)
+	tracks, err := deezer.chartTracks(ctx, genreID, 100)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	return shuffleAndLimit(tracks, limit), nil
+}
+
+func fetchMixedGenreTracks(ctx context.Context, limit int) ([]Track, error) {
+	allGenres := []string{"pop", "rock", "rap", "electronic", "indie", "classic", "country", "jazz", "blues", "reggae", "rnb", "soul", "metal", "alternative", "techno"}
+
+	tracksPerGenre := 10
+
+	allTracks, err := deezer.fanOut(ctx, allGenres, func(ctx context.Context, genre string) ([]Track, error) {
+		return fetchTracksFromGenre(ctx, genre, tracksPerGenre)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var result struct {
-		Data []struct {
-			Title   string `json:"title"`
-			Preview string `json:"preview"`
-			Artist  struct {
-				Name string `json:"name"`
-			} `json:"artist"`
-			Album struct {
-				Title string `json:"title"`
-			} `json:"album"`
-			Duration int `json:"duration"`
-		} `json:"data"`
+	return shuffleAndLimit(allTracks, limit), nil
+}
+
+func fetchFrenchTracks(ctx context.Context, limit int) ([]Track, error) {
+	frenchArtists := []string{
+		"Stromae", "Angèle", "Orelsan", "Edith Piaf", "Charles Aznavour",
+		"Indila", "Ninho", "Aya Nakamura", "Jul", "Soprano",
+		"Jacques Brel", "Dalida", "Claude François", "Johnny Hallyday",
+		"Zaz", "Céline Dion", "GIMS", "PNL", "Booba", "Nekfeu",
+		"Damso", "SCH", "Naps", "Maître Gims", "Black M",
+		"Louane", "Kendji Girac", "Vitaa", "Slimane", "Dadju",
 	}
 
-	err = json.Unmarshal(body, &result)
+	tracksPerArtist := 5
+
+	allTracks, err := deezer.fanOut(ctx, frenchArtists, func(ctx context.Context, artist string) ([]Track, error) {
+		return deezer.searchTracks(ctx, fmt.Sprintf("artist:%q", artist), tracksPerArtist)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	tracks := make([]Track, 0)
-	for _, item := range result.Data {
-		if item.Preview != "" {
-			tracks = append(tracks, Track{
-				Title:    item.Title,
-				Artist:   item.Artist.Name,
-				Preview:  item.Preview,
-				Album:    item.Album.Title,
-				Duration: item.Duration,
-			})
-		}
+	return shuffleAndLimit(allTracks, limit), nil
+}
+
+func fetchTracksFromGenre(ctx context.Context, genre string, limit int) ([]Track, error) {
+	genreID := getGenreID(genre)
+	if genreID == 0 {
+		return []Track{}, nil
 	}
 
-	return tracks, nil
+	return deezer.chartTracks(ctx, genreID, limit)
+}
+
+func shuffleAndLimit(tracks []Track, limit int) []Track {
+	rand.Shuffle(len(tracks), func(i, j int) {
+		tracks[i], tracks[j] = tracks[j], tracks[i]
+	})
+
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+
+	return tracks
 }
 
 func getGenreID(playlist string) int {
@@ -253,3 +506,40 @@ func getGenreID(playlist string) int {
 
 	return 0
 }
+
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(requests int, per time.Duration) *rateLimiter {
+	return &rateLimiter{interval: per / time.Duration(requests)}
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleepContext(ctx, delay)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,166 @@
+package blindtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newStubDeezer(t *testing.T, handler http.HandlerFunc) (*deezerClient, *int32) {
+	t.Helper()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := newDeezerClient(srv.URL)
+	client.RetryDelay = time.Millisecond
+	return client, &calls
+}
+
+func useStubDeezer(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	client, _ := newStubDeezer(t, handler)
+	prev := deezer
+	deezer = client
+	t.Cleanup(func() { deezer = prev })
+}
+
+func TestDeezerRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []func(http.ResponseWriter)
+		wantCalls int32
+		wantErr   bool
+	}{
+		{
+			name: "success",
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) { w.Write([]byte(`{"data":[{"id":1,"title":"A"}]}`)) },
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "server error then success",
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+				func(w http.ResponseWriter) { w.Write([]byte(`{"data":[]}`)) },
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "quota then success",
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Write([]byte(`{"error":{"type":"Exception","message":"Quota limit exceeded","code":4}}`))
+				},
+				func(w http.ResponseWriter) { w.Write([]byte(`{"data":[]}`)) },
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "dropped connection then success",
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+				},
+				func(w http.ResponseWriter) { w.Write([]byte(`{"data":[]}`)) },
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "too many requests",
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusTooManyRequests) },
+			},
+			wantCalls: 4,
+			wantErr:   true,
+		},
+		{
+			name: "not found is final",
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) },
+			},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name: "api error is final",
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Write([]byte(`{"error":{"type":"DataException","message":"no data","code":800}}`))
+				},
+			},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name: "malformed body is final",
+			responses: []func(http.ResponseWriter){
+				func(w http.ResponseWriter) { w.Write([]byte(`{"data":[`)) },
+			},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var served int32
+			client, calls := newStubDeezer(t, func(w http.ResponseWriter, r *http.Request) {
+				i := int(atomic.AddInt32(&served, 1)) - 1
+				if i >= len(tt.responses) {
+					i = len(tt.responses) - 1
+				}
+				tt.responses[i](w)
+			})
+
+			_, err := client.chartTracks(context.Background(), 0, 10)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(calls); got != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDeezerRetryAfter(t *testing.T) {
+	client, _ := newStubDeezer(t, func(w http.ResponseWriter, r *http.Request) {})
+	err := &deezerStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Second}
+	if got := client.backoff(1, err); got != 2*time.Second {
+		t.Fatalf("backoff = %v, want Retry-After", got)
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		delay := client.RetryDelay << (attempt - 1)
+		got := client.backoff(attempt, errors.New("boom"))
+		if got < delay/2 || got > delay/2+delay {
+			t.Fatalf("attempt %d: backoff %v outside [%v, %v]", attempt, got, delay/2, delay/2+delay)
+		}
+	}
+}
+
+func TestDeezerStopsOnCancel(t *testing.T) {
+	client, calls := newStubDeezer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	client.RetryDelay = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.chartTracks(ctx, 0, 10); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Fatalf("calls = %d, want 1", got)
+	}
+}
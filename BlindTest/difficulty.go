@@ -0,0 +1,122 @@
+package blindtest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	difficultyEasy   = "easy"
+	difficultyMedium = "medium"
+	difficultyHard   = "hard"
+)
+
+type difficultyProfile struct {
+	minPopularity float64
+	maxPopularity float64
+	weight        func(popularity float64) float64
+	multiplier    float64
+}
+
+var difficultyProfiles = map[string]difficultyProfile{
+	difficultyEasy: {
+		minPopularity: 0.5,
+		maxPopularity: 1,
+		weight:        func(p float64) float64 { return 0.1 + p*p },
+		multiplier:    0.8,
+	},
+	difficultyMedium: {
+		minPopularity: 0.2,
+		maxPopularity: 1,
+		weight:        func(p float64) float64 { return 1 },
+		multiplier:    1,
+	},
+	difficultyHard: {
+		minPopularity: 0,
+		maxPopularity: 0.6,
+		weight:        func(p float64) float64 { return 0.1 + (1-p)*(1-p) },
+		multiplier:    1.5,
+	},
+}
+
+func normalizeDifficulty(difficulty string) string {
+	if _, ok := difficultyProfiles[difficulty]; ok {
+		return difficulty
+	}
+	return difficultyMedium
+}
+
+func difficultyMultiplier(difficulty string) float64 {
+	return difficultyProfiles[normalizeDifficulty(difficulty)].multiplier
+}
+
+func popularityScores(tracks []Track) []float64 {
+	scores := make([]float64, len(tracks))
+	if len(tracks) < 2 {
+		for i := range scores {
+			scores[i] = 0.5
+		}
+		return scores
+	}
+
+	byRank := make([]int, len(tracks))
+	for i := range byRank {
+		byRank[i] = i
+	}
+	sort.SliceStable(byRank, func(a, b int) bool {
+		return tracks[byRank[a]].Rank < tracks[byRank[b]].Rank
+	})
+
+	maxPosition := 0
+	for _, t := range tracks {
+		if t.ChartPosition > maxPosition {
+			maxPosition = t.ChartPosition
+		}
+	}
+
+	for pos, i := range byRank {
+		score := float64(pos) / float64(len(tracks)-1)
+		if chart := tracks[i].ChartPosition; chart > 0 && maxPosition > 1 {
+			chartScore := 1 - float64(chart-1)/float64(maxPosition-1)
+			score = (score + chartScore) / 2
+		}
+		scores[i] = score
+	}
+	return scores
+}
+
+func applyDifficulty(tracks []Track, difficulty string, keep int) []Track {
+	profile := difficultyProfiles[normalizeDifficulty(difficulty)]
+	scores := popularityScores(tracks)
+
+	type weighted struct {
+		track Track
+		key   float64
+		fits  bool
+	}
+	entries := make([]weighted, len(tracks))
+	for i, t := range tracks {
+		p := scores[i]
+		entries[i] = weighted{
+			track: t,
+			key:   math.Pow(rand.Float64(), 1/profile.weight(p)),
+			fits:  p >= profile.minPopularity && p <= profile.maxPopularity,
+		}
+	}
+	sort.SliceStable(entries, func(a, b int) bool {
+		if entries[a].fits != entries[b].fits {
+			return entries[a].fits
+		}
+		return entries[a].key > entries[b].key
+	})
+
+	ordered := make([]Track, 0, len(entries))
+	for _, e := range entries {
+		if !e.fits && len(ordered) >= keep {
+			break
+		}
+		ordered = append(ordered, e.track)
+	}
+	return ordered
+}
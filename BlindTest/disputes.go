@@ -0,0 +1,219 @@
+package blindtest
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const disputeMinWindow = 10 * time.Second
+
+type Dispute struct {
+	ID       string
+	PlayerID string
+	GuessID  int
+	Field    string
+	Votes    map[string]bool
+	Resolved bool
+}
+
+func disputeField(room *Room, field string) string {
+	switch room.Mode {
+	case modeClassic:
+		if field == modeTitle || field == modeArtist {
+			return field
+		}
+	case modeTitle, modeArtist, modeAlbum:
+		return room.Mode
+	}
+	return ""
+}
+
+func alreadyFound(answer *PlayerAnswer, field string) bool {
+	switch field {
+	case modeTitle:
+		return answer.FoundTitle
+	case modeArtist:
+		return answer.FoundArtist
+	case modeAlbum:
+		return answer.FoundAlbum
+	}
+	return true
+}
+
+func openDispute(room *Room, player *Player, guessID int, field string) {
+	if room.Players[player.ID] != player || room.CurrentTrack == nil {
+		return
+	}
+
+	field = disputeField(room, field)
+	if field == "" {
+		sendError(player.Client, "Disputes are not available in this mode")
+		return
+	}
+
+	answer := room.PlayerAnswers[player.ID]
+	if answer == nil || guessID < 0 || guessID >= len(answer.Rejected) {
+		sendError(player.Client, "Guess not found")
+		return
+	}
+	guess := &answer.Rejected[guessID]
+	if guess.Disputed {
+		sendError(player.Client, "This guess was already disputed")
+		return
+	}
+	if alreadyFound(answer, field) {
+		sendError(player.Client, "You already found this answer")
+		return
+	}
+	for _, d := range room.Disputes {
+		if d.PlayerID == player.ID {
+			sendError(player.Client, "You already disputed a guess this round")
+			return
+		}
+	}
+
+	guess.Disputed = true
+	d := &Dispute{
+		ID:       uuid.New().String(),
+		PlayerID: player.ID,
+		GuessID:  guessID,
+		Field:    field,
+		Votes:    make(map[string]bool),
+	}
+	room.Disputes[d.ID] = d
+
+	broadcast(room, Message{
+		Type: "dispute_opened",
+		Data: map[string]interface{}{
+			"disputeId": d.ID,
+			"username":  player.Username,
+			"playerId":  player.ID,
+			"guess":     guess.Text,
+			"field":     field,
+			"needed":    disputeVotesNeeded(room, d),
+		},
+	})
+
+	if !room.Paused && timeRemaining(room) < disputeMinWindow {
+		schedule(room, disputeMinWindow, nextRound)
+	}
+}
+
+func disputeVoters(room *Room, d *Dispute) int {
+	count := 0
+	for id, p := range room.Players {
+		if p.Connected && id != d.PlayerID {
+			count++
+		}
+	}
+	return count
+}
+
+func disputeVotesNeeded(room *Room, d *Dispute) int {
+	return disputeVoters(room, d)/2 + 1
+}
+
+func voteDispute(room *Room, player *Player, disputeID string, accept bool) {
+	if room.Players[player.ID] != player {
+		return
+	}
+	d := room.Disputes[disputeID]
+	if d == nil || d.Resolved {
+		sendError(player.Client, "Dispute not found")
+		return
+	}
+	if d.PlayerID == player.ID {
+		sendError(player.Client, "You cannot vote on your own dispute")
+		return
+	}
+	d.Votes[player.ID] = accept
+
+	eligible := disputeVoters(room, d)
+	needed := eligible/2 + 1
+	yes, no := 0, 0
+	for id, vote := range d.Votes {
+		if p, ok := room.Players[id]; !ok || !p.Connected {
+			continue
+		}
+		if vote {
+			yes++
+		} else {
+			no++
+		}
+	}
+
+	broadcast(room, Message{
+		Type: "dispute_votes",
+		Data: map[string]interface{}{
+			"disputeId": d.ID,
+			"yes":       yes,
+			"no":        no,
+			"needed":    needed,
+		},
+	})
+
+	if yes >= needed {
+		resolveDispute(room, d, true)
+	} else if eligible-no < needed {
+		resolveDispute(room, d, false)
+	}
+}
+
+func hostResolveDispute(room *Room, player *Player, disputeID string, accept bool) {
+	d := room.Disputes[disputeID]
+	if d == nil || d.Resolved {
+		sendError(player.Client, "Dispute not found")
+		return
+	}
+	if d.PlayerID == player.ID {
+		sendError(player.Client, "You cannot resolve your own dispute")
+		return
+	}
+	resolveDispute(room, d, accept)
+}
+
+func resolveDispute(room *Room, d *Dispute, accepted bool) {
+	d.Resolved = true
+
+	player := room.Players[d.PlayerID]
+	answer := room.PlayerAnswers[d.PlayerID]
+	if player == nil || answer == nil {
+		broadcast(room, Message{
+			Type: "dispute_resolved",
+			Data: map[string]interface{}{
+				"disputeId": d.ID,
+				"accepted":  false,
+			},
+		})
+		return
+	}
+	guess := &answer.Rejected[d.GuessID]
+	before := room.RoundPoints[player.ID]
+
+	if accepted {
+		guess.Accepted = true
+		player.Streak = player.PrevStreak
+		if room.Mode == modeClassic {
+			handleClassicAnswer(room, player, answer, d.Field == modeTitle, d.Field == modeArtist, guess.At)
+		} else {
+			handleSingleAnswer(room, player, answer, guess.At)
+		}
+		updateStreak(room, player)
+		addAlias(d.Field, room.CurrentTrack, guess.Text)
+	}
+	recordRound(room)
+
+	broadcast(room, Message{
+		Type: "dispute_resolved",
+		Data: map[string]interface{}{
+			"disputeId": d.ID,
+			"username":  player.Username,
+			"guess":     guess.Text,
+			"field":     d.Field,
+			"accepted":  accepted,
+			"points":    room.RoundPoints[player.ID] - before,
+		},
+	})
+	broadcastPlayerList(room)
+}
@@ -0,0 +1,136 @@
+package blindtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"groupie-tracker/wsclient"
+)
+
+func testClient(t *testing.T) (*wsclient.Client, <-chan Message) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { peer.Close() })
+
+	messages := make(chan Message, 64)
+	go func() {
+		defer close(messages)
+		for {
+			var msg Message
+			if err := peer.ReadJSON(&msg); err != nil {
+				return
+			}
+			messages <- msg
+		}
+	}()
+
+	client := wsclient.New(<-conns)
+	t.Cleanup(client.Close)
+	return client, messages
+}
+
+func testRoom(t *testing.T, mode string, track *Track) *Room {
+	t.Helper()
+	room := createRoom(Message{Mode: mode})
+	room.cancel()
+	<-room.done
+
+	room.Phase = phasePlaying
+	room.CurrentTrack = track
+	room.RoundStartTime = time.Now()
+	room.RoundPoints = make(map[string]int)
+	return room
+}
+
+func testPlayer(t *testing.T, room *Room, id string) *Player {
+	t.Helper()
+	client, _ := testClient(t)
+	player := &Player{ID: id, Username: id, Client: client, Connected: true, JoinedAt: time.Now()}
+	room.Players[id] = player
+	return player
+}
+
+func TestResolveDisputeAddsAlias(t *testing.T) {
+	tests := []struct {
+		guess string
+		alias bool
+	}{
+		{"bohemian rapsodie", true},
+		{"bo", false},
+	}
+	for _, tt := range tests {
+		track := &Track{ID: 1, Title: "Bohemian Rhapsody", Artist: "Queen"}
+		key, _ := trackAliasKey(modeTitle, track)
+		t.Cleanup(func() { forgetAlias(key, normalizeAnswer(tt.guess)) })
+
+		room := testRoom(t, modeClassic, track)
+		player := testPlayer(t, room, "p1")
+		testPlayer(t, room, "p2")
+
+		if matchAnswer(tt.guess, track, room.MinCoverage).Title {
+			t.Fatalf("%q should not match before the dispute", tt.guess)
+		}
+		room.PlayerAnswers[player.ID] = &PlayerAnswer{
+			Rejected: []RejectedGuess{{Text: tt.guess, At: time.Now()}},
+		}
+		d := &Dispute{ID: "d1", PlayerID: player.ID, Field: modeTitle, Votes: make(map[string]bool)}
+		room.Disputes[d.ID] = d
+
+		resolveDispute(room, d, true)
+
+		if !room.PlayerAnswers[player.ID].FoundTitle {
+			t.Errorf("%q: accepted dispute should award the title", tt.guess)
+		}
+		got := matchAnswer(tt.guess, track, room.MinCoverage)
+		if got.Title != tt.alias {
+			t.Errorf("%q: matches after dispute = %v, want %v", tt.guess, got.Title, tt.alias)
+		}
+		if tt.alias && got.TitleAlias != normalizeAnswer(tt.guess) {
+			t.Errorf("%q: matched via %q, want the new alias", tt.guess, got.TitleAlias)
+		}
+	}
+}
+
+func TestResolveDisputeRejected(t *testing.T) {
+	track := &Track{ID: 2, Title: "Hey Jude", Artist: "The Beatles"}
+	room := testRoom(t, modeClassic, track)
+	player := testPlayer(t, room, "p1")
+	testPlayer(t, room, "p2")
+
+	room.PlayerAnswers[player.ID] = &PlayerAnswer{
+		Rejected: []RejectedGuess{{Text: "hey judy", At: time.Now()}},
+	}
+	d := &Dispute{ID: "d1", PlayerID: player.ID, Field: modeTitle, Votes: make(map[string]bool)}
+	room.Disputes[d.ID] = d
+
+	resolveDispute(room, d, false)
+
+	if room.PlayerAnswers[player.ID].FoundTitle || room.RoundPoints[player.ID] != 0 {
+		t.Error("rejected dispute should not award points")
+	}
+	key, _ := trackAliasKey(modeTitle, track)
+	aliasesMu.RLock()
+	defer aliasesMu.RUnlock()
+	if len(aliases[key]) != 0 {
+		t.Errorf("rejected dispute added aliases %v", aliases[key])
+	}
+}
@@ -1,102 +1,212 @@
 package blindtest
 
 import (
+	"context"
+	"errors"
 	"log"
 	"time"
 )
 
-func startGame(room *Room) {
-	room.mu.Lock()
-	room.GameStarted = true
-	room.RoundNumber = 0
-	room.mu.Unlock()
+const (
+	gameStartDelay = 2 * time.Second
+)
+
+func startGame(room *Room) bool {
+	if room.Phase != phaseLobby || len(room.Players) == 0 {
+		return false
+	}
+	resetGame(room)
+	if !setPhase(room, phaseLoading) {
+		return false
+	}
+	roomEvent(room, "game_started")
+
+	go loadTracks(room, room.Playlist, room.MaxRounds, room.Mode, newTrackSelection(room))
+	return true
+}
+
+func loadTracks(room *Room, playlist string, maxRounds int, mode string, selection trackSelection) {
+	ctx, cancel := context.WithTimeout(room.ctx, 30*time.Second)
+	defer cancel()
+
+	limit := maxRounds
+	if mode == modeChoice {
+		limit = maxRounds * choicePoolFactor
+	}
+	var (
+		tracks []Track
+		report *recipeReport
+		err    error
+	)
+	if selection.recipe != nil {
+		tracks, report, err = buildRecipe(ctx, selection.recipe, maxRounds, limit, selection)
+	} else {
+		tracks, err = fetchPlaylistPool(ctx, playlist, limit*selectionPoolFactor)
+		if err == nil {
+			tracks = applyDifficulty(tracks, selection.difficulty, 2*limit)
+			tracks = selectTracks(tracks, limit, selection.heard())
+		}
+	}
+	if err == nil && mode == modeYear {
+		deezer.fillReleaseDates(ctx, tracks)
+		tracks = tracksWithYear(tracks)
+	}
+	rounds := min(maxRounds, len(tracks))
+	copy(tracks, spreadArtists(tracks[:rounds]))
+	if err == nil {
+		deezer.fillTrackDetails(ctx, tracks[:rounds])
+	}
+
+	sendCommand(room, tracksCommand{tracks: tracks, report: report, err: err})
+}
 
-	tracks, err := fetchTracksFromDeezer(room.Playlist, room.MaxRounds)
+func handleTracksLoaded(room *Room, tracks []Track, report *recipeReport, err error) {
+	if room.Phase != phaseLoading {
+		return
+	}
+	if report != nil {
+		broadcast(room, Message{
+			Type: "recipe_report",
+			Data: map[string]interface{}{
+				"parts":     report.Parts,
+				"requested": report.Requested,
+				"found":     report.Found,
+				"filled":    report.Filled,
+			},
+		})
+	}
+	if err == nil && len(tracks) == 0 {
+		err = errors.New("no tracks returned")
+	}
 	if err != nil {
 		log.Println("Error fetching tracks:", err)
+		abortLoading(room)
 		return
 	}
 
+	room.ChoicePool = tracks
+	if len(tracks) > room.MaxRounds {
+		tracks = tracks[:room.MaxRounds]
+	}
 	room.Tracks = tracks
 
-	room.mu.RLock()
-	for _, player := range room.Players {
-		player.Conn.WriteJSON(Message{
-			Type: "game_start",
-			Data: map[string]interface{}{
-				"maxRounds": room.MaxRounds,
-			},
-		})
+	broadcast(room, Message{
+		Type: "game_start",
+		Data: map[string]interface{}{
+			"maxRounds":  room.MaxRounds,
+			"mode":       room.Mode,
+			"difficulty": room.Difficulty,
+		},
+	})
+
+	recordGameStart(room)
+	setPhase(room, phaseCountdown)
+	schedule(room, gameStartDelay, nextRound)
+	startTicker(room)
+}
+
+func abortLoading(room *Room) {
+	roomEvent(room, "game_aborted")
+	resetGame(room)
+	for _, p := range room.Players {
+		p.Ready = false
 	}
-	room.mu.RUnlock()
+	setPhase(room, phaseLobby)
+
+	broadcast(room, Message{
+		Type: "error",
+		Data: map[string]interface{}{
+			"message": "Could not load tracks, please try again",
+			"code":    "load_failed",
+		},
+	})
+	broadcastPlayerList(room)
+}
 
-	time.Sleep(2 * time.Second)
+func nextRound(room *Room) {
+	i := room.RoundNumber
+	if i >= room.MaxRounds || i >= len(room.Tracks) {
+		endGame(room)
+		return
+	}
 
-	for i := 0; i < room.MaxRounds && i < len(room.Tracks); i++ {
-		room.mu.Lock()
-		room.CurrentTrackIdx = i
-		room.CurrentTrack = &room.Tracks[i]
-		room.RoundNumber = i + 1
-		room.RoundStartTime = time.Now()
-		room.CorrectAnswers = make(map[string]bool)
-		room.PlayerAnswers = make(map[string]*PlayerAnswer)
-		room.mu.Unlock()
+	room.CurrentTrackIdx = i
+	room.CurrentTrack = &room.Tracks[i]
+	room.RoundNumber = i + 1
+	room.PlayedTracks[room.CurrentTrack.ID] = true
+	room.RoundStartTime = time.Now().Add(playbackLead)
+	room.PlaybackStarts = make(map[string]time.Time)
+	room.CorrectAnswers = make(map[string]bool)
+	room.PlayerAnswers = make(map[string]*PlayerAnswer)
+	room.TeamFinders = make(map[string]string)
+	room.RoundPoints = make(map[string]int)
+	room.Disputes = make(map[string]*Dispute)
+	room.FirstFinder = ""
+	room.Paused = false
+	if room.Mode == modeChoice {
+		buildChoices(room)
+	}
 
-		startRound(room)
-		time.Sleep(time.Duration(room.RoundTime) * time.Second)
-		endRound(room)
-		time.Sleep(5 * time.Second)
+	if !setPhase(room, phasePlaying) {
+		return
 	}
 
-	endGame(room)
+	issueRoundAudio(room)
+	go prefetchCover(room.CurrentTrack.AlbumID)
+	startRound(room)
+	schedule(room, time.Duration(room.RoundTime)*time.Second+playbackLead, endRound)
 }
 
 func startRound(room *Room) {
-	room.mu.RLock()
-	defer room.mu.RUnlock()
-
 	msg := Message{
 		Type: "round_start",
 		Data: map[string]interface{}{
-			"round":   room.RoundNumber,
-			"preview": room.CurrentTrack.Preview,
+			"round":      room.RoundNumber,
+			"preview":    audioURL(room.AudioToken),
+			"playAt":     serverMillis(room.RoundStartTime),
+			"serverTime": serverMillis(time.Now()),
 		},
 	}
-
-	for _, player := range room.Players {
-		player.Conn.WriteJSON(msg)
+	if room.Mode == modeChoice {
+		msg.Data["options"] = room.Options
 	}
+
+	broadcast(room, msg)
 }
 
 func endRound(room *Room) {
-	room.mu.RLock()
-	defer room.mu.RUnlock()
+	if !setPhase(room, phaseReveal) {
+		return
+	}
 
 	msg := Message{
 		Type: "round_end",
-		Data: map[string]interface{}{
-			"title":  room.CurrentTrack.Title,
-			"artist": room.CurrentTrack.Artist,
-			"album":  room.CurrentTrack.Album,
-		},
-	}
-
-	for _, player := range room.Players {
-		player.Conn.WriteJSON(msg)
+		Data: roundEndPayload(room),
 	}
+	msg.Data["intermission"] = room.Intermission
+	recordRound(room)
+	updateStreaks(room)
+	room.SkipVotes = make(map[string]bool)
 
+	broadcast(room, msg)
 	broadcastPlayerList(room)
+
+	schedule(room, time.Duration(room.Intermission)*time.Second, nextRound)
 }
 
 func endGame(room *Room) {
-	room.mu.RLock()
-	defer room.mu.RUnlock()
+	if !setPhase(room, phaseFinished) {
+		return
+	}
+	roomEvent(room, "game_finished")
+	stopTicker(room)
 
 	players := make([]map[string]interface{}, 0)
 	for _, p := range room.Players {
 		players = append(players, map[string]interface{}{
 			"username": p.Username,
 			"score":    p.Score,
+			"team":     p.Team,
 		})
 	}
 
@@ -104,10 +214,13 @@ func endGame(room *Room) {
 		Type: "game_end",
 		Data: map[string]interface{}{
 			"players": players,
+			"teams":   teamStandings(room),
 		},
 	}
 
-	for _, player := range room.Players {
-		player.Conn.WriteJSON(msg)
-	}
+	broadcast(room, msg)
+	recordGameEnd(room, "finished")
+	promoteWaitingSpectators(room)
+
+	broadcastPlayerList(room)
 }
@@ -0,0 +1,602 @@
+package blindtest
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+const historyQueueSize = 256
+
+var (
+	btDB        *sql.DB
+	dbOnce      sync.Once
+	dbErr       error
+	historyJobs = make(chan func(*sql.DB), historyQueueSize)
+)
+
+type historyGame struct {
+	ID              string          `json:"id"`
+	RoomCode        string          `json:"roomCode"`
+	Mode            string          `json:"mode"`
+	Playlist        string          `json:"playlist"`
+	MaxRounds       int             `json:"maxRounds"`
+	Status          string          `json:"status"`
+	StartedAt       time.Time       `json:"startedAt"`
+	EndedAt         *time.Time      `json:"endedAt,omitempty"`
+	Players         []historyPlayer `json:"players"`
+	Teams           []historyTeam   `json:"teams,omitempty"`
+	Rounds          []historyRound  `json:"rounds,omitempty"`
+	TeamAggregation string          `json:"teamAggregation,omitempty"`
+}
+
+type historyPlayer struct {
+	Key      string `json:"-"`
+	UserID   int    `json:"userId,omitempty"`
+	Username string `json:"username"`
+	Score    int    `json:"score"`
+	Team     string `json:"team,omitempty"`
+	Rank     int    `json:"rank"`
+}
+
+type historyTeam struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	Rank  int    `json:"rank"`
+}
+
+type historyRound struct {
+	Number   int             `json:"number"`
+	TrackID  int64           `json:"trackId"`
+	Title    string          `json:"title"`
+	Artist   string          `json:"artist"`
+	Album    string          `json:"album"`
+	AlbumID  int64           `json:"-"`
+	Cover    string          `json:"cover,omitempty"`
+	Year     int             `json:"year,omitempty"`
+	Link     string          `json:"link,omitempty"`
+	Explicit bool            `json:"explicit"`
+	BPM      float64         `json:"bpm,omitempty"`
+	Results  []historyResult `json:"results"`
+}
+
+type historyResult struct {
+	Key         string `json:"-"`
+	UserID      int    `json:"userId,omitempty"`
+	Username    string `json:"username"`
+	FoundTitle  bool   `json:"foundTitle"`
+	FoundArtist bool   `json:"foundArtist"`
+	TitleMs     int64  `json:"titleMs,omitempty"`
+	ArtistMs    int64  `json:"artistMs,omitempty"`
+	Points      int    `json:"points"`
+}
+
+type historyGuess struct {
+	Key       string
+	UserID    int
+	Username  string
+	Index     int
+	Guess     string
+	ElapsedMs int64
+	Disputed  bool
+	Accepted  bool
+}
+
+func initBlindTestStore() error {
+	dbOnce.Do(func() {
+		btDB, dbErr = sql.Open("sqlite", "./main.db")
+		if dbErr != nil {
+			return
+		}
+		if err := createBlindTestTables(btDB); err != nil {
+			dbErr = err
+			return
+		}
+		if err := createPlaylistTables(btDB); err != nil {
+			dbErr = err
+			return
+		}
+		if err := createAliasTables(btDB); err != nil {
+			dbErr = err
+			return
+		}
+		if err := loadAliases(btDB); err != nil {
+			dbErr = err
+			return
+		}
+		go runHistoryWriter(btDB)
+	})
+	return dbErr
+}
+
+func createBlindTestTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS blindtest_games (
+			id TEXT PRIMARY KEY,
+			room_code TEXT NOT NULL,
+			mode TEXT NOT NULL,
+			playlist TEXT,
+			max_rounds INTEGER,
+			round_time INTEGER,
+			team_aggregation TEXT,
+			status TEXT NOT NULL DEFAULT 'playing',
+			started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			ended_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS blindtest_game_players (
+			game_id TEXT NOT NULL REFERENCES blindtest_games(id),
+			player_key TEXT NOT NULL,
+			user_id INTEGER REFERENCES users(id),
+			username TEXT NOT NULL,
+			score INTEGER DEFAULT 0,
+			team TEXT,
+			rank INTEGER,
+			PRIMARY KEY (game_id, player_key)
+		);`,
+		`CREATE TABLE IF NOT EXISTS blindtest_game_teams (
+			game_id TEXT NOT NULL REFERENCES blindtest_games(id),
+			team_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			score INTEGER DEFAULT 0,
+			rank INTEGER,
+			PRIMARY KEY (game_id, team_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS blindtest_rounds (
+			game_id TEXT NOT NULL REFERENCES blindtest_games(id),
+			round_number INTEGER NOT NULL,
+			track_id INTEGER,
+			title TEXT,
+			artist TEXT,
+			album TEXT,
+			album_id INTEGER,
+			release_year INTEGER,
+			link TEXT,
+			explicit INTEGER DEFAULT 0,
+			bpm REAL,
+			started_at DATETIME,
+			PRIMARY KEY (game_id, round_number)
+		);`,
+		`CREATE TABLE IF NOT EXISTS blindtest_round_results (
+			game_id TEXT NOT NULL,
+			round_number INTEGER NOT NULL,
+			player_key TEXT NOT NULL,
+			user_id INTEGER REFERENCES users(id),
+			username TEXT NOT NULL,
+			found_title INTEGER DEFAULT 0,
+			found_artist INTEGER DEFAULT 0,
+			title_ms INTEGER,
+			artist_ms INTEGER,
+			points INTEGER DEFAULT 0,
+			PRIMARY KEY (game_id, round_number, player_key)
+		);`,
+		`CREATE TABLE IF NOT EXISTS blindtest_rejected_guesses (
+			game_id TEXT NOT NULL,
+			round_number INTEGER NOT NULL,
+			player_key TEXT NOT NULL,
+			guess_index INTEGER NOT NULL,
+			user_id INTEGER REFERENCES users(id),
+			username TEXT NOT NULL,
+			guess TEXT NOT NULL,
+			elapsed_ms INTEGER,
+			disputed INTEGER DEFAULT 0,
+			accepted INTEGER DEFAULT 0,
+			PRIMARY KEY (game_id, round_number, player_key, guess_index)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_blindtest_game_players_user ON blindtest_game_players(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_blindtest_round_results_user ON blindtest_round_results(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_blindtest_rounds_album ON blindtest_rounds(album_id);`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func playerKey(p *Player) string {
+	if p.UserID != 0 {
+		return "user:" + strconv.Itoa(p.UserID)
+	}
+	return p.ID
+}
+
+func runHistoryWriter(db *sql.DB) {
+	for job := range historyJobs {
+		job(db)
+	}
+}
+
+func queueHistory(job func(*sql.DB)) {
+	if btDB == nil {
+		return
+	}
+	select {
+	case historyJobs <- job:
+	default:
+		log.Println("BlindTest: history queue full, dropping write")
+	}
+}
+
+func recordGameStart(room *Room) {
+	room.GameID = uuid.New().String()
+	id, code, mode, playlist := room.GameID, room.ID, room.Mode, room.Playlist
+	maxRounds, roundTime, aggregation := room.MaxRounds, room.RoundTime, room.TeamAggregation
+
+	queueHistory(func(db *sql.DB) {
+		_, err := db.Exec(`INSERT INTO blindtest_games(id, room_code, mode, playlist, max_rounds, round_time, team_aggregation)
+			VALUES(?, ?, ?, ?, ?, ?, ?)`,
+			id, code, mode, playlist, maxRounds, roundTime, aggregation)
+		if err != nil {
+			log.Println("BlindTest: record game:", err)
+		}
+	})
+}
+
+func recordRound(room *Room) {
+	if room.GameID == "" || room.CurrentTrack == nil {
+		return
+	}
+
+	track := room.CurrentTrack
+	round := historyRound{
+		Number:   room.RoundNumber,
+		TrackID:  track.ID,
+		Title:    track.Title,
+		Artist:   track.Artist,
+		Album:    track.Album,
+		AlbumID:  track.AlbumID,
+		Year:     track.Year(),
+		Link:     track.Link,
+		Explicit: track.Explicit,
+		BPM:      track.BPM,
+	}
+	var guesses []historyGuess
+	for id, p := range room.Players {
+		result := historyResult{
+			Key:      playerKey(p),
+			UserID:   p.UserID,
+			Username: p.Username,
+			Points:   room.RoundPoints[id],
+		}
+		if answer := room.PlayerAnswers[id]; answer != nil {
+			result.FoundTitle = answer.FoundTitle
+			result.FoundArtist = answer.FoundArtist
+			if answer.FoundTitle {
+				result.TitleMs = listeningElapsed(room, p, answer.TimeTitle).Milliseconds()
+			}
+			if answer.FoundArtist {
+				result.ArtistMs = listeningElapsed(room, p, answer.TimeArtist).Milliseconds()
+			}
+			for i, g := range answer.Rejected {
+				guesses = append(guesses, historyGuess{
+					Key:       playerKey(p),
+					UserID:    p.UserID,
+					Username:  p.Username,
+					Index:     i,
+					Guess:     g.Text,
+					ElapsedMs: listeningElapsed(room, p, g.At).Milliseconds(),
+					Disputed:  g.Disputed,
+					Accepted:  g.Accepted,
+				})
+			}
+		}
+		round.Results = append(round.Results, result)
+	}
+	gameID, startedAt := room.GameID, room.RoundStartTime
+
+	queueHistory(func(db *sql.DB) {
+		tx, err := db.Begin()
+		if err != nil {
+			log.Println("BlindTest: record round:", err)
+			return
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(`INSERT OR REPLACE INTO blindtest_rounds(game_id, round_number, track_id, title, artist, album, album_id, release_year, link, explicit, bpm, started_at)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			gameID, round.Number, round.TrackID, round.Title, round.Artist, round.Album,
+			round.AlbumID, round.Year, round.Link, round.Explicit, round.BPM, startedAt)
+		if err != nil {
+			log.Println("BlindTest: record round:", err)
+			return
+		}
+		for _, r := range round.Results {
+			_, err = tx.Exec(`INSERT OR REPLACE INTO blindtest_round_results(game_id, round_number, player_key, user_id, username, found_title, found_artist, title_ms, artist_ms, points)
+				VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				gameID, round.Number, r.Key, nullUserID(r.UserID), r.Username, r.FoundTitle, r.FoundArtist, r.TitleMs, r.ArtistMs, r.Points)
+			if err != nil {
+				log.Println("BlindTest: record round result:", err)
+				return
+			}
+		}
+		for _, g := range guesses {
+			_, err = tx.Exec(`INSERT OR REPLACE INTO blindtest_rejected_guesses(game_id, round_number, player_key, guess_index, user_id, username, guess, elapsed_ms, disputed, accepted)
+				VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				gameID, round.Number, g.Key, g.Index, nullUserID(g.UserID), g.Username, g.Guess, g.ElapsedMs, g.Disputed, g.Accepted)
+			if err != nil {
+				log.Println("BlindTest: record rejected guess:", err)
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			log.Println("BlindTest: record round:", err)
+		}
+	})
+}
+
+func recordGameEnd(room *Room, status string) {
+	if room.GameID == "" {
+		return
+	}
+
+	players := make([]historyPlayer, 0, len(room.Players))
+	for _, p := range room.Players {
+		players = append(players, historyPlayer{
+			Key:      playerKey(p),
+			UserID:   p.UserID,
+			Username: p.Username,
+			Score:    p.Score,
+			Team:     p.Team,
+		})
+	}
+	sort.SliceStable(players, func(i, j int) bool {
+		return players[i].Score > players[j].Score
+	})
+	for i := range players {
+		players[i].Rank = i + 1
+	}
+
+	teams := make([]historyTeam, 0, len(room.Teams))
+	for i, t := range teamStandings(room) {
+		teams = append(teams, historyTeam{
+			ID:    t["id"].(string),
+			Name:  t["name"].(string),
+			Score: t["score"].(int),
+			Rank:  i + 1,
+		})
+	}
+	gameID := room.GameID
+	room.GameID = ""
+
+	queueHistory(func(db *sql.DB) {
+		tx, err := db.Begin()
+		if err != nil {
+			log.Println("BlindTest: record game end:", err)
+			return
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`UPDATE blindtest_games SET status = ?, ended_at = CURRENT_TIMESTAMP WHERE id = ?`, status, gameID); err != nil {
+			log.Println("BlindTest: record game end:", err)
+			return
+		}
+		for _, p := range players {
+			_, err := tx.Exec(`INSERT OR REPLACE INTO blindtest_game_players(game_id, player_key, user_id, username, score, team, rank)
+				VALUES(?, ?, ?, ?, ?, ?, ?)`,
+				gameID, p.Key, nullUserID(p.UserID), p.Username, p.Score, p.Team, p.Rank)
+			if err != nil {
+				log.Println("BlindTest: record game player:", err)
+				return
+			}
+		}
+		for _, t := range teams {
+			_, err := tx.Exec(`INSERT OR REPLACE INTO blindtest_game_teams(game_id, team_id, name, score, rank)
+				VALUES(?, ?, ?, ?, ?)`,
+				gameID, t.ID, t.Name, t.Score, t.Rank)
+			if err != nil {
+				log.Println("BlindTest: record game team:", err)
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			log.Println("BlindTest: record game end:", err)
+		}
+	})
+}
+
+func nullUserID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func fetchUserHistory(userID, limit int) ([]historyGame, error) {
+	if btDB == nil {
+		return nil, nil
+	}
+	rows, err := btDB.Query(`SELECT g.id, g.room_code, g.mode, g.playlist, g.max_rounds, g.status, g.started_at, g.ended_at, COALESCE(g.team_aggregation, '')
+		FROM blindtest_games g
+		JOIN blindtest_game_players gp ON gp.game_id = g.id
+		WHERE gp.user_id = ?
+		ORDER BY g.started_at DESC
+		LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	games := make([]historyGame, 0)
+	for rows.Next() {
+		var g historyGame
+		var endedAt sql.NullTime
+		if err := rows.Scan(&g.ID, &g.RoomCode, &g.Mode, &g.Playlist, &g.MaxRounds, &g.Status, &g.StartedAt, &endedAt, &g.TeamAggregation); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if endedAt.Valid {
+			g.EndedAt = &endedAt.Time
+		}
+		games = append(games, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range games {
+		if games[i].Players, err = fetchGamePlayers(games[i].ID); err != nil {
+			return nil, err
+		}
+		if games[i].Teams, err = fetchGameTeams(games[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return games, nil
+}
+
+func fetchGamePlayers(gameID string) ([]historyPlayer, error) {
+	rows, err := btDB.Query(`SELECT COALESCE(user_id, 0), username, score, COALESCE(team, ''), rank
+		FROM blindtest_game_players WHERE game_id = ? ORDER BY rank`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	players := make([]historyPlayer, 0)
+	for rows.Next() {
+		var p historyPlayer
+		if err := rows.Scan(&p.UserID, &p.Username, &p.Score, &p.Team, &p.Rank); err != nil {
+			return nil, err
+		}
+		players = append(players, p)
+	}
+	return players, rows.Err()
+}
+
+func fetchGameTeams(gameID string) ([]historyTeam, error) {
+	rows, err := btDB.Query(`SELECT team_id, name, score, rank
+		FROM blindtest_game_teams WHERE game_id = ? ORDER BY rank`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []historyTeam
+	for rows.Next() {
+		var t historyTeam
+		if err := rows.Scan(&t.ID, &t.Name, &t.Score, &t.Rank); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	return teams, rows.Err()
+}
+
+func fetchGameRounds(gameID string) ([]historyRound, error) {
+	rows, err := btDB.Query(`SELECT r.round_number, COALESCE(r.track_id, 0), COALESCE(r.title, ''), COALESCE(r.artist, ''), COALESCE(r.album, ''),
+			COALESCE(r.album_id, 0), COALESCE(r.release_year, 0), COALESCE(r.link, ''), COALESCE(r.explicit, 0), COALESCE(r.bpm, 0),
+			COALESCE(rr.user_id, 0), COALESCE(rr.username, ''), COALESCE(rr.found_title, 0), COALESCE(rr.found_artist, 0),
+			COALESCE(rr.title_ms, 0), COALESCE(rr.artist_ms, 0), COALESCE(rr.points, 0)
+		FROM blindtest_rounds r
+		LEFT JOIN blindtest_round_results rr ON rr.game_id = r.game_id AND rr.round_number = r.round_number
+		WHERE r.game_id = ?
+		ORDER BY r.round_number, rr.points DESC`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rounds := make([]historyRound, 0)
+	for rows.Next() {
+		var round historyRound
+		var result historyResult
+		if err := rows.Scan(&round.Number, &round.TrackID, &round.Title, &round.Artist, &round.Album,
+			&round.AlbumID, &round.Year, &round.Link, &round.Explicit, &round.BPM,
+			&result.UserID, &result.Username, &result.FoundTitle, &result.FoundArtist,
+			&result.TitleMs, &result.ArtistMs, &result.Points); err != nil {
+			return nil, err
+		}
+		if len(rounds) == 0 || rounds[len(rounds)-1].Number != round.Number {
+			round.Cover = coverURL(round.AlbumID)
+			round.Results = make([]historyResult, 0)
+			rounds = append(rounds, round)
+		}
+		if result.Username != "" {
+			last := &rounds[len(rounds)-1]
+			last.Results = append(last.Results, result)
+		}
+	}
+	return rounds, rows.Err()
+}
+
+func fetchGame(gameID string, userID int) (*historyGame, error) {
+	if btDB == nil {
+		return nil, sql.ErrNoRows
+	}
+	var g historyGame
+	var endedAt sql.NullTime
+	err := btDB.QueryRow(`SELECT g.id, g.room_code, g.mode, g.playlist, g.max_rounds, g.status, g.started_at, g.ended_at, COALESCE(g.team_aggregation, '')
+		FROM blindtest_games g
+		JOIN blindtest_game_players gp ON gp.game_id = g.id
+		WHERE g.id = ? AND gp.user_id = ?`, gameID, userID).
+		Scan(&g.ID, &g.RoomCode, &g.Mode, &g.Playlist, &g.MaxRounds, &g.Status, &g.StartedAt, &endedAt, &g.TeamAggregation)
+	if err != nil {
+		return nil, err
+	}
+	if endedAt.Valid {
+		g.EndedAt = &endedAt.Time
+	}
+	if g.Players, err = fetchGamePlayers(gameID); err != nil {
+		return nil, err
+	}
+	if g.Teams, err = fetchGameTeams(gameID); err != nil {
+		return nil, err
+	}
+	if g.Rounds, err = fetchGameRounds(gameID); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func historyLimit(raw string) int {
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 || limit > 100 {
+		return 20
+	}
+	return limit
+}
+
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if gameID := r.URL.Query().Get("game"); gameID != "" {
+		game, err := fetchGame(gameID, user.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "game not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println("BlindTest: history:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, game)
+		return
+	}
+
+	games, err := fetchUserHistory(user.ID, historyLimit(r.URL.Query().Get("limit")))
+	if err != nil {
+		log.Println("BlindTest: history:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, map[string]interface{}{"games": games})
+}
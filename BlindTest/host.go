@@ -0,0 +1,204 @@
+package blindtest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+func isHost(room *Room, player *Player) bool {
+	return room.HostID == player.ID
+}
+
+func migrateHost(room *Room) bool {
+	host, ok := room.Players[room.HostID]
+	if ok && host.Connected {
+		return false
+	}
+
+	var next *Player
+	for _, p := range room.Players {
+		if !p.Connected {
+			continue
+		}
+		if next == nil || p.JoinedAt.Before(next.JoinedAt) {
+			next = p
+		}
+	}
+
+	if next == nil {
+		if ok {
+			return false
+		}
+		room.HostID = ""
+		return true
+	}
+	room.HostID = next.ID
+	return true
+}
+
+func broadcastHost(room *Room) {
+	msg := Message{
+		Type: "host_changed",
+		Data: map[string]interface{}{
+			"hostId": room.HostID,
+		},
+	}
+
+	broadcast(room, msg)
+}
+
+func banKey(player *Player) string {
+	if player.UserID != 0 {
+		return "user:" + strconv.Itoa(player.UserID)
+	}
+	return "name:" + strings.ToLower(strings.TrimSpace(player.Username))
+}
+
+func kickPlayer(room *Room, targetID string, ban bool) bool {
+	target, ok := room.Players[targetID]
+	if !ok {
+		target, ok = room.Spectators[targetID]
+	}
+	if !ok || targetID == room.HostID {
+		return false
+	}
+	delete(room.Players, targetID)
+	delete(room.Spectators, targetID)
+	checkRoundComplete(room)
+	if ban {
+		room.Banned[banKey(target)] = true
+	}
+
+	reason := "kicked"
+	if ban {
+		reason = "banned"
+	}
+	target.Client.Send(Message{
+		Type: "kicked",
+		Data: map[string]interface{}{
+			"reason": reason,
+		},
+	})
+	target.Client.CloseAfterFlush()
+
+	broadcastPlayerList(room)
+	checkAllReady(room)
+	return true
+}
+
+func setPaused(room *Room, paused bool) bool {
+	if !inGame(room) || room.Phase == phaseLoading || room.Paused == paused {
+		return false
+	}
+
+	room.Paused = paused
+	if paused {
+		room.PausedAt = time.Now()
+		pauseTimer(room)
+	} else {
+		pausedFor := time.Since(room.PausedAt)
+		room.RoundStartTime = room.RoundStartTime.Add(pausedFor)
+		shiftPlaybackStarts(room, pausedFor)
+		resumeTimer(room)
+		extendAudioToken(room.AudioToken, timeRemaining(room)+audioTokenGrace)
+	}
+
+	msg := Message{
+		Type: "round_paused",
+		Data: map[string]interface{}{
+			"paused": paused,
+		},
+	}
+	broadcast(room, msg)
+	return true
+}
+
+func skipTrack(room *Room) bool {
+	if room.Phase != phasePlaying {
+		return false
+	}
+	cancelTimer(room)
+	endRound(room)
+	return true
+}
+
+func updateSettings(room *Room, msg Message) bool {
+	if room.Phase != phaseLobby {
+		return false
+	}
+
+	if msg.MaxRounds != 0 {
+		room.MaxRounds = clampMaxRounds(msg.MaxRounds)
+	}
+	if msg.RoundTime != 0 {
+		room.RoundTime = clampRoundTime(msg.RoundTime)
+	}
+	if msg.Intermission != 0 {
+		room.Intermission = clampIntermission(msg.Intermission)
+	}
+	if msg.Playlist != "" {
+		room.Playlist = msg.Playlist
+		room.Recipe = nil
+	}
+	if recipe := normalizeRecipe(msg.Recipe); recipe != nil {
+		room.Playlist = recipePlaylist
+		room.Recipe = recipe
+	}
+	if msg.Difficulty != "" {
+		room.Difficulty = normalizeDifficulty(msg.Difficulty)
+	}
+	if msg.AllowJoin != nil {
+		room.AllowSpectatorJoin = *msg.AllowJoin
+	}
+
+	update := Message{
+		Type: "settings",
+		Data: roomSettings(room),
+	}
+	broadcast(room, update)
+	return true
+}
+
+func roomSettings(room *Room) map[string]interface{} {
+	return map[string]interface{}{
+		"maxRounds":    room.MaxRounds,
+		"roundTime":    room.RoundTime,
+		"intermission": room.Intermission,
+		"playlist":     room.Playlist,
+		"mode":         room.Mode,
+		"scoring":      room.Scoring,
+		"difficulty":   room.Difficulty,
+		"recipe":       room.Recipe,
+
+		"allowSpectatorJoin": room.AllowSpectatorJoin,
+	}
+}
+
+func handleHostCommand(room *Room, player *Player, msg Message) {
+	if !isHost(room, player) {
+		sendError(player.Client, "Only the host can do that")
+		return
+	}
+
+	switch msg.Type {
+	case "kick":
+		kickPlayer(room, msg.TargetID, false)
+	case "ban":
+		kickPlayer(room, msg.TargetID, true)
+	case "start_now":
+		startGame(room)
+	case "pause":
+		setPaused(room, true)
+	case "resume":
+		setPaused(room, false)
+	case "skip":
+		skipTrack(room)
+	case "update_settings":
+		updateSettings(room, msg)
+	case "play_again":
+		playAgain(room)
+	case "resolve_dispute":
+		hostResolveDispute(room, player, msg.DisputeID, msg.Accept)
+	}
+}
@@ -0,0 +1,64 @@
+package blindtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMigrateHost(t *testing.T) {
+	tests := []struct {
+		name      string
+		hostGone  bool
+		hostAway  bool
+		away      []string
+		want      string
+		wantMoved bool
+	}{
+		{name: "host still connected", want: "p0"},
+		{name: "host left", hostGone: true, want: "p1", wantMoved: true},
+		{name: "host disconnected", hostAway: true, want: "p1", wantMoved: true},
+		{name: "skips disconnected players", hostGone: true, away: []string{"p1"}, want: "p2", wantMoved: true},
+		{name: "keeps a disconnected host when nobody else is here", hostAway: true, away: []string{"p1", "p2"}, want: "p0"},
+		{name: "no host when everyone left is disconnected", hostGone: true, away: []string{"p1", "p2"}, want: "", wantMoved: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := &Room{Players: make(map[string]*Player), HostID: "p0"}
+			start := time.Now()
+			for i, id := range []string{"p0", "p1", "p2"} {
+				room.Players[id] = &Player{ID: id, Connected: true, JoinedAt: start.Add(time.Duration(i) * time.Second)}
+			}
+			if tt.hostGone {
+				delete(room.Players, "p0")
+			}
+			if tt.hostAway {
+				room.Players["p0"].Connected = false
+			}
+			for _, id := range tt.away {
+				room.Players[id].Connected = false
+			}
+
+			moved := migrateHost(room)
+			if moved != tt.wantMoved || room.HostID != tt.want {
+				t.Errorf("migrateHost = %v, host %q; want %v, host %q", moved, room.HostID, tt.wantMoved, tt.want)
+			}
+		})
+	}
+}
+
+func TestKickLastUnreadyPlayerStartsGame(t *testing.T) {
+	room := testRoom(t, modeClassic, nil)
+	room.Phase = phaseLobby
+	host := testPlayer(t, room, "p0")
+	room.HostID = host.ID
+	host.Ready = true
+	testPlayer(t, room, "p1").Ready = true
+	testPlayer(t, room, "p2")
+
+	if !kickPlayer(room, "p2", false) {
+		t.Fatal("kick failed")
+	}
+	if room.Phase != phaseLoading {
+		t.Errorf("phase = %s, want %s once everyone left is ready", room.Phase, phaseLoading)
+	}
+}
@@ -0,0 +1,138 @@
+package blindtest
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	janitorInterval = time.Minute
+	lobbyTTL        = 15 * time.Minute
+	finishedTTL     = 10 * time.Minute
+	gameTTL         = 2 * time.Hour
+)
+
+type sweepCommand struct {
+	now time.Time
+}
+
+type lifecycleMetrics struct {
+	mu       sync.Mutex
+	counters map[string]int64
+}
+
+var metrics = &lifecycleMetrics{counters: make(map[string]int64)}
+
+func (m *lifecycleMetrics) inc(name string) {
+	m.mu.Lock()
+	m.counters[name]++
+	m.mu.Unlock()
+}
+
+func (m *lifecycleMetrics) snapshot() map[string]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[string]int64, len(m.counters)+1)
+	for name, value := range m.counters {
+		out[name] = value
+	}
+
+	roomsMu.RLock()
+	out["rooms_active"] = int64(len(rooms))
+	roomsMu.RUnlock()
+	return out
+}
+
+func roomEvent(room *Room, event string) {
+	metrics.inc(event)
+	log.Printf("BlindTest: room %s %s (phase %s, %d players)", room.ID, event, room.Phase, len(room.Players))
+}
+
+func closeRoom(room *Room, reason string) {
+	if room.closed {
+		return
+	}
+	if inGame(room) {
+		roomEvent(room, "game_aborted")
+		recordGameEnd(room, "aborted")
+	}
+
+	cancelTimer(room)
+	stopTicker(room)
+	revokeAudioToken(room.AudioToken)
+	room.cancel()
+
+	roomsMu.Lock()
+	if rooms[room.ID] == room {
+		delete(rooms, room.ID)
+	}
+	roomsMu.Unlock()
+
+	broadcast(room, Message{
+		Type: "room_closed",
+		Data: map[string]interface{}{
+			"reason": reason,
+		},
+	})
+	room.closed = true
+
+	metrics.inc("room_closed_" + reason)
+	roomEvent(room, "room_closed")
+}
+
+func sweepRoom(room *Room, now time.Time) {
+	idle := now.Sub(room.lastActivity)
+
+	switch {
+	case room.Phase == phaseLobby && idle > lobbyTTL:
+		closeRoom(room, "idle_lobby")
+	case room.Phase == phaseFinished && idle > finishedTTL:
+		closeRoom(room, "idle_finished")
+	case idle > gameTTL:
+		closeRoom(room, "idle")
+	}
+}
+
+func runJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		sweepRooms(now)
+	}
+}
+
+func sweepRooms(now time.Time) {
+	roomsMu.RLock()
+	list := make([]*Room, 0, len(rooms))
+	for _, room := range rooms {
+		list = append(list, room)
+	}
+	roomsMu.RUnlock()
+
+	for _, room := range list {
+		select {
+		case <-room.done:
+			roomsMu.Lock()
+			if rooms[room.ID] == room {
+				delete(rooms, room.ID)
+				metrics.inc("room_reclaimed")
+			}
+			roomsMu.Unlock()
+			continue
+		default:
+		}
+
+		select {
+		case room.commands <- sweepCommand{now: now}:
+		default:
+		}
+	}
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, metrics.snapshot())
+}
@@ -0,0 +1,249 @@
+package blindtest
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+type matchResult struct {
+	Title  bool
+	Artist bool
+	Album  bool
+
+	TitleAlias  string
+	ArtistAlias string
+	AlbumAlias  string
+}
+
+var (
+	bracketPattern  = regexp.MustCompile(`\s*[\(\[\{][^\)\]\}]*[\)\]\}]`)
+	featPattern     = regexp.MustCompile(`(?i)\s+(feat\.?|ft\.?|featuring)\s.*$`)
+	suffixPattern   = regexp.MustCompile(`(?i)\s+-\s+.*\b(live|remaster(ed)?|version|edit|mix|remix|acoustic|acoustique|radio|mono|stereo|bonus|demo|instrumental|extended|single|original)\b.*$`)
+	leadingArticles = map[string]bool{
+		"the": true, "a": true, "an": true,
+		"le": true, "la": true, "les": true, "l": true, "un": true, "une": true,
+	}
+	fillerWords = map[string]bool{
+		"the": true, "a": true, "an": true, "of": true, "and": true, "by": true,
+		"le": true, "la": true, "les": true, "l": true, "un": true, "une": true,
+		"de": true, "du": true, "des": true, "d": true, "et": true, "par": true,
+		"c": true, "est": true, "feat": true, "ft": true,
+	}
+	specialLetters = strings.NewReplacer(
+		"œ", "oe", "Œ", "oe", "æ", "ae", "Æ", "ae", "ß", "ss",
+		"ø", "o", "Ø", "o", "ł", "l", "Ł", "l", "đ", "d", "Đ", "d",
+		"&", " and ", "$", "s",
+	)
+)
+
+func matchAnswer(answer string, track *Track, minCoverage float64) matchResult {
+	if track == nil {
+		return matchResult{}
+	}
+
+	normalizedAnswer := normalizeAnswer(answer)
+	if normalizedAnswer == "" {
+		return matchResult{}
+	}
+
+	var result matchResult
+	result.Title, result.TitleAlias = matchField(normalizedAnswer, modeTitle, track.Title, track, minCoverage)
+	result.Artist, result.ArtistAlias = matchField(normalizedAnswer, modeArtist, track.Artist, track, minCoverage)
+	result.Album, result.AlbumAlias = matchField(normalizedAnswer, modeAlbum, track.Album, track, minCoverage)
+	if result != (matchResult{}) && !preciseAnswer(normalizedAnswer, track) {
+		return matchResult{}
+	}
+	return result
+}
+
+// preciseAnswer rejects guesses padded with words foreign to the track, so
+// that listing many titles at once does not score.
+func preciseAnswer(answer string, track *Track) bool {
+	fields := [][2]string{{modeTitle, track.Title}, {modeArtist, track.Artist}, {modeAlbum, track.Album}}
+	var targets []string
+	for _, field := range fields {
+		if target := normalizeAnswer(cleanTarget(field[1])); target != "" {
+			targets = append(targets, target)
+		}
+		targets = append(targets, trackAliases(field[0], track)...)
+	}
+
+	matched, extra := 0, 0
+	for _, word := range strings.Fields(answer) {
+		switch {
+		case knownWord(word, targets):
+			matched++
+		case !fillerWords[word]:
+			extra++
+		}
+	}
+	return extra <= 1+matched/4
+}
+
+func knownWord(word string, targets []string) bool {
+	for _, target := range targets {
+		compactTarget := compact(target)
+		if tolerance := typoTolerance(compactTarget); levenshtein(word, compactTarget) <= tolerance {
+			return true
+		}
+		for _, targetWord := range strings.Fields(target) {
+			tolerance := typoTolerance(targetWord)
+			if word == targetWord || (tolerance > 0 && levenshtein(word, targetWord) <= tolerance) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchField(answer, kind, target string, track *Track, minCoverage float64) (bool, string) {
+	if matchesTarget(answer, normalizeAnswer(cleanTarget(target)), minCoverage) {
+		return true, ""
+	}
+	for _, alias := range trackAliases(kind, track) {
+		if matchesTarget(answer, alias, minCoverage) {
+			return true, alias
+		}
+	}
+	return false, ""
+}
+
+func matchesTarget(answer, target string, minCoverage float64) bool {
+	if answer == "" || target == "" {
+		return false
+	}
+
+	compactTarget := compact(target)
+	if strings.Contains(" "+answer+" ", " "+target+" ") {
+		return true
+	}
+
+	answerWords := strings.Fields(answer)
+	targetWords := strings.Fields(target)
+	if fuzzyContains(answerWords, compactTarget, len(targetWords)) {
+		return true
+	}
+
+	covered := coverage(answerWords, targetWords)
+	return covered > 0 && covered >= minCoverage
+}
+
+func coverage(answerWords, targetWords []string) float64 {
+	if len(targetWords) == 0 {
+		return 0
+	}
+
+	used := make([]bool, len(answerWords))
+	covered := 0
+	for _, targetWord := range targetWords {
+		tolerance := typoTolerance(targetWord)
+		for i, answerWord := range answerWords {
+			if used[i] {
+				continue
+			}
+			if answerWord == targetWord || (tolerance > 0 && levenshtein(answerWord, targetWord) <= tolerance) {
+				used[i] = true
+				covered++
+				break
+			}
+		}
+	}
+	return float64(covered) / float64(len(targetWords))
+}
+
+func fuzzyContains(answerWords []string, compactTarget string, targetWords int) bool {
+	tolerance := typoTolerance(compactTarget)
+	if tolerance == 0 {
+		return false
+	}
+
+	for size := targetWords - 1; size <= targetWords+1; size++ {
+		if size < 1 || size > len(answerWords) {
+			continue
+		}
+		for start := 0; start+size <= len(answerWords); start++ {
+			window := strings.Join(answerWords[start:start+size], "")
+			if levenshtein(window, compactTarget) <= tolerance {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typoTolerance(compactTarget string) int {
+	length := len([]rune(compactTarget))
+	switch {
+	case length <= 3:
+		return 0
+	case length <= 6:
+		return 1
+	case length <= 12:
+		return 2
+	}
+	return length / 6
+}
+
+func cleanTarget(s string) string {
+	s = bracketPattern.ReplaceAllString(s, "")
+	s = featPattern.ReplaceAllString(s, "")
+	s = suffixPattern.ReplaceAllString(s, "")
+	return s
+}
+
+func normalizeAnswer(s string) string {
+	words := strings.Fields(foldString(s))
+	if len(words) > 1 && leadingArticles[words[0]] {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+func foldString(s string) string {
+	s = specialLetters.Replace(s)
+	folder := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(folder, s)
+	if err != nil {
+		folded = s
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(folded) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return b.String()
+}
+
+func compact(s string) string {
+	return strings.ReplaceAll(s, " ", "")
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,186 @@
+package blindtest
+
+import "testing"
+
+func TestNormalizeAnswer(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Édith Piaf", "edith piaf"},
+		{"Cœur de pirate", "coeur de pirate"},
+		{"Simon & Garfunkel", "simon and garfunkel"},
+		{"The Beatles", "beatles"},
+		{"La Bohème", "boheme"},
+		{"L'Aventurier", "aventurier"},
+		{"  Ça   plane  pour moi!! ", "ca plane pour moi"},
+		{"Ke$ha", "kesha"},
+		{"The", "the"},
+	}
+	for _, tt := range tests {
+		if got := normalizeAnswer(tt.in); got != tt.want {
+			t.Errorf("normalizeAnswer(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCleanTarget(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Alors on danse (Radio Edit)", "Alors on danse"},
+		{"Bella [Clean]", "Bella"},
+		{"Papaoutai feat. Someone", "Papaoutai"},
+		{"Tchiki Tchiki ft Someone", "Tchiki Tchiki"},
+		{"Bohemian Rhapsody - Remastered 2011", "Bohemian Rhapsody"},
+		{"La Vie en rose - Live", "La Vie en rose"},
+		{"Hey Jude - Single Version", "Hey Jude"},
+		{"Ne me quitte pas", "Ne me quitte pas"},
+		{"Back in Black - Acoustique", "Back in Black"},
+	}
+	for _, tt := range tests {
+		if got := cleanTarget(tt.in); got != tt.want {
+			t.Errorf("cleanTarget(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAnswer(t *testing.T) {
+	track := &Track{
+		Title:  "Bohemian Rhapsody - Remastered 2011",
+		Artist: "Queen",
+		Album:  "A Night at the Opera",
+	}
+	tests := []struct {
+		answer string
+		want   matchResult
+	}{
+		{"bohemian rhapsody", matchResult{Title: true}},
+		{"BOHEMIAN RHAPSODY!", matchResult{Title: true}},
+		{"bohemain rapsody", matchResult{Title: true}},
+		{"bohemianrhapsody", matchResult{Title: true}},
+		{"queen", matchResult{Artist: true}},
+		{"bohemian rhapsody queen", matchResult{Title: true, Artist: true}},
+		{"night at the opera", matchResult{Album: true}},
+		{"rhapsody", matchResult{}},
+		{"bohemian", matchResult{}},
+		{"quen", matchResult{Artist: true}},
+		{"king", matchResult{}},
+		{"", matchResult{}},
+		{"!!!", matchResult{}},
+	}
+	for _, tt := range tests {
+		if got := matchAnswer(tt.answer, track, defaultMinCoverage); got != tt.want {
+			t.Errorf("matchAnswer(%q) = %+v, want %+v", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAnswerFolding(t *testing.T) {
+	track := &Track{Title: "Ça plane pour moi", Artist: "Plastic Bertrand"}
+	tests := []struct {
+		answer string
+		title  bool
+		artist bool
+	}{
+		{"ca plane pour moi", true, false},
+		{"ÇA PLANE POUR MOI", true, false},
+		{"plane pour moi", true, false},
+		{"plastik bertrand", false, true},
+		{"bertrand", false, false},
+	}
+	for _, tt := range tests {
+		got := matchAnswer(tt.answer, track, defaultMinCoverage)
+		if got.Title != tt.title || got.Artist != tt.artist {
+			t.Errorf("matchAnswer(%q) = %+v, want title=%v artist=%v", tt.answer, got, tt.title, tt.artist)
+		}
+	}
+}
+
+func TestMatchAnswerWordBoundaries(t *testing.T) {
+	tests := []struct {
+		answer string
+		artist string
+		want   bool
+	}{
+		{"muse", "Muse", true},
+		{"amusement park", "Muse", false},
+		{"shaggy", "Shaggy", true},
+		{"shaggydog", "Shaggy", false},
+		{"daftpunk", "Daft Punk", true},
+		{"daft punk", "Daft Punk", true},
+		{"daftpunks", "Daft Punk", true},
+	}
+	for _, tt := range tests {
+		track := &Track{Title: "Song", Artist: tt.artist}
+		if got := matchAnswer(tt.answer, track, defaultMinCoverage).Artist; got != tt.want {
+			t.Errorf("matchAnswer(%q) artist %q = %v, want %v", tt.answer, tt.artist, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAnswerPrecision(t *testing.T) {
+	tests := []struct {
+		answer string
+		title  string
+		artist string
+		want   matchResult
+	}{
+		{"alors on danse stromae", "Alors on danse", "Stromae", matchResult{Title: true, Artist: true}},
+		{"c'est alors on danse de stromae", "Alors on danse", "Stromae", matchResult{Title: true, Artist: true}},
+		{"alors on danse par stromae 2010", "Alors on danse", "Stromae", matchResult{Title: true, Artist: true}},
+		{"stromae angele orelsan jul pnl booba alors on danse papaoutai formidable", "Alors on danse", "Stromae", matchResult{}},
+		{"angele stromae", "Alors on danse", "Stromae", matchResult{Artist: true}},
+		{"angele orelsan stromae", "Alors on danse", "Stromae", matchResult{}},
+		{"i will always love you", "I Will Always Love You", "Whitney Houston", matchResult{Title: true}},
+		{"i you love me my will always the baby", "I Will Always Love You", "Whitney Houston", matchResult{}},
+		{"bohemianrhapsody queen", "Bohemian Rhapsody", "Queen", matchResult{Title: true, Artist: true}},
+	}
+	for _, tt := range tests {
+		track := &Track{Title: tt.title, Artist: tt.artist}
+		if got := matchAnswer(tt.answer, track, defaultMinCoverage); got != tt.want {
+			t.Errorf("matchAnswer(%q) = %+v, want %+v", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAnswerAliases(t *testing.T) {
+	track := &Track{Title: "Bella", Artist: "Maître Gims"}
+	key, _ := trackAliasKey(modeArtist, track)
+	storeAlias(key, "gims")
+	t.Cleanup(func() { forgetAlias(key, "gims") })
+
+	tests := []struct {
+		answer string
+		alias  string
+	}{
+		{"maitre gims", ""},
+		{"gims", "gims"},
+		{"GIMS", "gims"},
+	}
+	for _, tt := range tests {
+		got := matchAnswer(tt.answer, track, defaultMinCoverage)
+		if !got.Artist || got.ArtistAlias != tt.alias {
+			t.Errorf("matchAnswer(%q) = %+v, want artist via alias %q", tt.answer, got, tt.alias)
+		}
+	}
+}
+
+func TestTypoTolerance(t *testing.T) {
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"abc", 0},
+		{"queen", 1},
+		{"beatles", 2},
+		{"bohemianrhapsody", 2},
+		{"supercalifragilistic", 3},
+	}
+	for _, tt := range tests {
+		if got := typoTolerance(tt.target); got != tt.want {
+			t.Errorf("typoTolerance(%q) = %d, want %d", tt.target, got, tt.want)
+		}
+	}
+}
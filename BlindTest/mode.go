@@ -0,0 +1,199 @@
+package blindtest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	modeClassic = "classic"
+	modeTitle   = "title"
+	modeArtist  = "artist"
+	modeAlbum   = "album"
+	modeYear    = "year"
+	modeChoice  = "choice"
+)
+
+func normalizeMode(mode string) string {
+	switch mode {
+	case modeTitle, modeArtist, modeAlbum, modeYear, modeChoice:
+		return mode
+	}
+	return modeClassic
+}
+
+func handleSingleAnswer(room *Room, player *Player, playerAnswer *PlayerAnswer, at time.Time) {
+	switch room.Mode {
+	case modeTitle:
+		playerAnswer.FoundTitle = true
+		playerAnswer.TimeTitle = at
+	case modeArtist:
+		playerAnswer.FoundArtist = true
+		playerAnswer.TimeArtist = at
+	case modeAlbum:
+		playerAnswer.FoundAlbum = true
+		playerAnswer.TimeAlbum = at
+	}
+	room.CorrectAnswers[player.ID] = true
+
+	breakdown := scoreAnswer(room, player, false, at)
+	points := breakdown.Total
+	awardPoints(room, player, points)
+
+	broadcast(room, Message{
+		Type: "correct_answer",
+		Data: map[string]interface{}{
+			"username":   player.Username,
+			"points":     points,
+			"breakdown":  breakdown,
+			"answerType": room.Mode,
+			"color":      "green",
+			"team":       player.Team,
+			"teams":      teamStandings(room),
+		},
+	})
+}
+
+func handleYearAnswer(room *Room, player *Player, playerAnswer *PlayerAnswer, answer string) {
+	if playerAnswer.YearGuess != 0 {
+		sendWrongAnswer(player, answer, 0)
+		return
+	}
+
+	year, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || year < 1000 || year > time.Now().Year()+1 {
+		sendWrongAnswer(player, answer, 1)
+		return
+	}
+
+	playerAnswer.YearGuess = year
+	playerAnswer.Guesses++
+
+	player.Client.Send(Message{
+		Type: "guess_received",
+		Data: map[string]interface{}{
+			"guess": year,
+		},
+	})
+
+	broadcast(room, Message{
+		Type: "player_guessed",
+		Data: map[string]interface{}{
+			"username": player.Username,
+		},
+	})
+}
+
+func scoreYearGuesses(room *Room) []map[string]interface{} {
+	trueYear := room.CurrentTrack.Year()
+	results := make([]map[string]interface{}, 0)
+
+	for id, p := range room.Players {
+		playerAnswer := room.PlayerAnswers[id]
+		if playerAnswer == nil || playerAnswer.YearGuess == 0 {
+			continue
+		}
+
+		distance := playerAnswer.YearGuess - trueYear
+		if distance < 0 {
+			distance = -distance
+		}
+
+		points := 0
+		if trueYear > 0 {
+			points = yearPoints(distance)
+		}
+		breakdown := applyStreak(room, p, pointsBreakdown{Base: points, Ratio: 1}, float64(points))
+		points = breakdown.Total
+		awardPoints(room, p, points)
+
+		results = append(results, map[string]interface{}{
+			"username":  p.Username,
+			"guess":     playerAnswer.YearGuess,
+			"distance":  distance,
+			"points":    points,
+			"breakdown": breakdown,
+		})
+	}
+
+	return results
+}
+
+func yearPoints(distance int) int {
+	if distance == 0 {
+		return 1000
+	} else if distance == 1 {
+		return 700
+	} else if distance == 2 {
+		return 500
+	} else if distance <= 5 {
+		return 300
+	} else if distance <= 10 {
+		return 100
+	}
+	return 0
+}
+
+func tracksWithYear(tracks []Track) []Track {
+	dated := make([]Track, 0, len(tracks))
+	for _, t := range tracks {
+		if t.Year() > 0 {
+			dated = append(dated, t)
+		}
+	}
+	if len(dated) == 0 {
+		return tracks
+	}
+	return dated
+}
+
+func roundEndPayload(room *Room) map[string]interface{} {
+	track := room.CurrentTrack
+	revealAlbum(track.AlbumID)
+	data := map[string]interface{}{
+		"mode":     room.Mode,
+		"title":    track.Title,
+		"artist":   track.Artist,
+		"album":    track.Album,
+		"cover":    coverURL(track.AlbumID),
+		"year":     track.Year(),
+		"link":     track.Link,
+		"explicit": track.Explicit,
+	}
+	if track.BPM > 0 {
+		data["bpm"] = track.BPM
+	}
+
+	switch room.Mode {
+	case modeYear:
+		data["guesses"] = scoreYearGuesses(room)
+	case modeChoice:
+		data["correctOptionId"] = room.CorrectOption
+		data["options"] = room.Options
+		data["picks"] = scoreChoices(room)
+	case modeTitle, modeArtist, modeAlbum:
+		answer := track.Title
+		if room.Mode == modeArtist {
+			answer = track.Artist
+		} else if room.Mode == modeAlbum {
+			answer = track.Album
+		}
+		data["answer"] = answer
+		data["found"] = foundUsernames(room)
+	default:
+		data["found"] = foundUsernames(room)
+	}
+
+	return data
+}
+
+func foundUsernames(room *Room) []string {
+	found := make([]string, 0)
+	for id := range room.CorrectAnswers {
+		if p, ok := room.Players[id]; ok {
+			found = append(found, p.Username)
+		}
+	}
+	return found
+}
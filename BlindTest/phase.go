@@ -0,0 +1,106 @@
+package blindtest
+
+import "log"
+
+const (
+	phaseLobby     = "lobby"
+	phaseLoading   = "loading"
+	phaseCountdown = "countdown"
+	phasePlaying   = "playing"
+	phaseReveal    = "reveal"
+	phaseFinished  = "finished"
+)
+
+var phaseTransitions = map[string][]string{
+	phaseLobby:     {phaseLoading},
+	phaseLoading:   {phaseCountdown, phaseLobby},
+	phaseCountdown: {phasePlaying},
+	phasePlaying:   {phaseReveal},
+	phaseReveal:    {phasePlaying, phaseFinished},
+	phaseFinished:  {phaseLobby},
+}
+
+var phaseMessages = map[string][]string{
+	phaseLobby:     {"ready", "choose_team", "join_as_player", "kick", "ban", "start_now", "update_settings"},
+	phaseLoading:   {"join_as_player", "kick", "ban"},
+	phaseCountdown: {"join_as_player", "kick", "ban", "pause", "resume"},
+	phasePlaying:   {"answer", "playback_started", "join_as_player", "kick", "ban", "pause", "resume", "skip"},
+	phaseReveal:    {"join_as_player", "kick", "ban", "pause", "resume", "vote_skip", "dispute", "dispute_vote", "resolve_dispute"},
+	phaseFinished:  {"join_as_player", "kick", "ban", "play_again"},
+}
+
+func allowedMessage(room *Room, msgType string) bool {
+	for _, t := range phaseMessages[room.Phase] {
+		if t == msgType {
+			return true
+		}
+	}
+	return false
+}
+
+func setPhase(room *Room, phase string) bool {
+	allowed := false
+	for _, next := range phaseTransitions[room.Phase] {
+		if next == phase {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		log.Printf("BlindTest: room %s cannot go from %s to %s", room.ID, room.Phase, phase)
+		return false
+	}
+
+	room.Phase = phase
+	broadcast(room, Message{
+		Type: "phase",
+		Data: map[string]interface{}{
+			"phase": phase,
+			"round": room.RoundNumber,
+		},
+	})
+	return true
+}
+
+func inGame(room *Room) bool {
+	return room.Phase != phaseLobby && room.Phase != phaseFinished
+}
+
+func playAgain(room *Room) bool {
+	if room.Phase != phaseFinished {
+		return false
+	}
+
+	cancelTimer(room)
+	resetGame(room)
+	for _, p := range room.Players {
+		p.Score = 0
+		p.Ready = false
+		p.Streak = 0
+	}
+	for _, team := range room.Teams {
+		team.Score = 0
+	}
+
+	if !setPhase(room, phaseLobby) {
+		return false
+	}
+	broadcastPlayerList(room)
+	return true
+}
+
+func resetGame(room *Room) {
+	room.RoundNumber = 0
+	room.CurrentTrack = nil
+	room.CurrentTrackIdx = 0
+	room.Tracks = nil
+	room.ChoicePool = nil
+	room.Options = nil
+	room.CorrectOption = ""
+	room.CorrectAnswers = make(map[string]bool)
+	room.PlayerAnswers = make(map[string]*PlayerAnswer)
+	room.Paused = false
+	room.GameID = ""
+	revokeAudioToken(room.AudioToken)
+	room.AudioToken = ""
+}
@@ -0,0 +1,490 @@
+package blindtest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	savedPlaylistPrefix = "saved:"
+	maxPlaylistTracks   = 200
+	maxPlaylistName     = 60
+	maxImportArtists    = 50
+	importPerArtist     = 5
+	trackSearchLimit    = 25
+	playlistTimeout     = 30 * time.Second
+)
+
+var errPlaylistNotFound = errors.New("playlist not found")
+
+type savedPlaylist struct {
+	ID         int64     `json:"id"`
+	Key        string    `json:"key"`
+	OwnerID    int       `json:"ownerId"`
+	Owner      string    `json:"owner"`
+	Name       string    `json:"name"`
+	Shared     bool      `json:"shared"`
+	TrackCount int       `json:"trackCount"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	Tracks     []Track   `json:"tracks,omitempty"`
+}
+
+type playlistRequest struct {
+	Name           string   `json:"name"`
+	Shared         *bool    `json:"shared"`
+	TrackIDs       []int64  `json:"trackIds"`
+	DeezerPlaylist int64    `json:"deezerPlaylistId"`
+	Artists        []string `json:"artists"`
+}
+
+func (req playlistRequest) hasTracks() bool {
+	return len(req.TrackIDs) > 0 || req.DeezerPlaylist != 0 || len(req.Artists) > 0
+}
+
+func savedPlaylistKey(id int64) string {
+	return savedPlaylistPrefix + strconv.FormatInt(id, 10)
+}
+
+func parseSavedPlaylist(playlist string) (int64, bool) {
+	if !strings.HasPrefix(playlist, savedPlaylistPrefix) {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(playlist, savedPlaylistPrefix), 10, 64)
+	return id, err == nil && id > 0
+}
+
+func createPlaylistTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS blindtest_playlists (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			owner_id INTEGER NOT NULL REFERENCES users(id),
+			name TEXT NOT NULL,
+			shared INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS blindtest_playlist_tracks (
+			playlist_id INTEGER NOT NULL REFERENCES blindtest_playlists(id) ON DELETE CASCADE,
+			position INTEGER NOT NULL,
+			track_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			artist TEXT NOT NULL,
+			album TEXT,
+			album_id INTEGER,
+			preview TEXT,
+			duration INTEGER,
+			release_date TEXT,
+			rank INTEGER,
+			PRIMARY KEY (playlist_id, track_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_blindtest_playlists_owner ON blindtest_playlists(owner_id);`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resolvePlaylistTracks(ctx context.Context, req playlistRequest) ([]Track, error) {
+	var tracks []Track
+
+	if len(req.TrackIDs) > 0 {
+		ids := req.TrackIDs
+		if len(ids) > maxPlaylistTracks {
+			ids = ids[:maxPlaylistTracks]
+		}
+		found := make([]Track, len(ids))
+		errs := deezer.parallel(ctx, len(ids), func(ctx context.Context, i int) error {
+			track, err := deezer.track(ctx, ids[i])
+			if err != nil {
+				return fmt.Errorf("track %d: %w", ids[i], err)
+			}
+			found[i] = track
+			return nil
+		})
+		if len(errs) > 0 {
+			log.Printf("BlindTest: %d/%d playlist tracks missing: %v", len(errs), len(ids), errors.Join(errs...))
+		}
+		for _, t := range found {
+			if t.ID != 0 && t.Preview != "" {
+				tracks = append(tracks, t)
+			}
+		}
+	}
+
+	if req.DeezerPlaylist != 0 {
+		imported, err := deezer.playlistTracks(ctx, req.DeezerPlaylist, maxPlaylistTracks)
+		if err != nil {
+			return nil, fmt.Errorf("import playlist %d: %w", req.DeezerPlaylist, err)
+		}
+		tracks = append(tracks, imported...)
+	}
+
+	if len(req.Artists) > 0 {
+		artists := make([]string, 0, len(req.Artists))
+		for _, a := range req.Artists {
+			if a = strings.TrimSpace(a); a != "" {
+				artists = append(artists, a)
+			}
+		}
+		if len(artists) > maxImportArtists {
+			artists = artists[:maxImportArtists]
+		}
+		imported, err := deezer.fanOut(ctx, artists, func(ctx context.Context, artist string) ([]Track, error) {
+			return deezer.searchTracks(ctx, fmt.Sprintf("artist:%q", artist), importPerArtist)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("import artists: %w", err)
+		}
+		tracks = append(tracks, imported...)
+	}
+
+	seen := make(map[int64]bool, len(tracks))
+	unique := tracks[:0]
+	for _, t := range tracks {
+		if seen[t.ID] {
+			continue
+		}
+		seen[t.ID] = true
+		unique = append(unique, t)
+	}
+	if len(unique) > maxPlaylistTracks {
+		unique = unique[:maxPlaylistTracks]
+	}
+	return unique, nil
+}
+
+func writePlaylistTracks(tx *sql.Tx, playlistID int64, tracks []Track) error {
+	if _, err := tx.Exec(`DELETE FROM blindtest_playlist_tracks WHERE playlist_id = ?`, playlistID); err != nil {
+		return err
+	}
+	for i, t := range tracks {
+		_, err := tx.Exec(`INSERT INTO blindtest_playlist_tracks(playlist_id, position, track_id, title, artist, album, album_id, preview, duration, release_date, rank)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			playlistID, i, t.ID, t.Title, t.Artist, t.Album, t.AlbumID, t.Preview, t.Duration, t.ReleaseDate, t.Rank)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createPlaylist(ownerID int, name string, shared bool, tracks []Track) (int64, error) {
+	tx, err := btDB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO blindtest_playlists(owner_id, name, shared) VALUES(?, ?, ?)`, ownerID, name, shared)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := writePlaylistTracks(tx, id, tracks); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func updatePlaylist(id int64, ownerID int, name string, shared *bool, tracks []Track) error {
+	tx, err := btDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE blindtest_playlists
+		SET name = COALESCE(NULLIF(?, ''), name), shared = COALESCE(?, shared), updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND owner_id = ?`, name, shared, id, ownerID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errPlaylistNotFound
+	}
+	if tracks != nil {
+		if err := writePlaylistTracks(tx, id, tracks); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func deletePlaylist(id int64, ownerID int) error {
+	tx, err := btDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM blindtest_playlists WHERE id = ? AND owner_id = ?`, id, ownerID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errPlaylistNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM blindtest_playlist_tracks WHERE playlist_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const playlistColumns = `p.id, p.owner_id, COALESCE(u.pseudo, ''), p.name, p.shared, p.created_at, p.updated_at,
+	(SELECT COUNT(*) FROM blindtest_playlist_tracks t WHERE t.playlist_id = p.id)`
+
+func scanPlaylist(row interface{ Scan(...interface{}) error }) (savedPlaylist, error) {
+	var p savedPlaylist
+	err := row.Scan(&p.ID, &p.OwnerID, &p.Owner, &p.Name, &p.Shared, &p.CreatedAt, &p.UpdatedAt, &p.TrackCount)
+	p.Key = savedPlaylistKey(p.ID)
+	return p, err
+}
+
+func fetchPlaylists(userID int) ([]savedPlaylist, error) {
+	rows, err := btDB.Query(`SELECT `+playlistColumns+`
+		FROM blindtest_playlists p
+		LEFT JOIN users u ON u.id = p.owner_id
+		WHERE p.owner_id = ? OR p.shared = 1
+		ORDER BY p.owner_id = ? DESC, p.updated_at DESC`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := []savedPlaylist{}
+	for rows.Next() {
+		p, err := scanPlaylist(rows)
+		if err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, p)
+	}
+	return playlists, rows.Err()
+}
+
+func fetchPlaylist(id int64, userID int) (savedPlaylist, error) {
+	p, err := scanPlaylist(btDB.QueryRow(`SELECT `+playlistColumns+`
+		FROM blindtest_playlists p
+		LEFT JOIN users u ON u.id = p.owner_id
+		WHERE p.id = ? AND (p.owner_id = ? OR p.shared = 1)`, id, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, errPlaylistNotFound
+	}
+	if err != nil {
+		return p, err
+	}
+
+	p.Tracks, err = fetchPlaylistTracks(id)
+	return p, err
+}
+
+func fetchPlaylistTracks(id int64) ([]Track, error) {
+	rows, err := btDB.Query(`SELECT track_id, title, artist, COALESCE(album, ''), COALESCE(album_id, 0), COALESCE(preview, ''),
+			COALESCE(duration, 0), COALESCE(release_date, ''), COALESCE(rank, 0)
+		FROM blindtest_playlist_tracks
+		WHERE playlist_id = ?
+		ORDER BY position`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []Track{}
+	for rows.Next() {
+		var t Track
+		if err := rows.Scan(&t.ID, &t.Title, &t.Artist, &t.Album, &t.AlbumID, &t.Preview, &t.Duration, &t.ReleaseDate, &t.Rank); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, t)
+	}
+	return tracks, rows.Err()
+}
+
+func canUsePlaylist(playlist string, userID int) bool {
+	id, ok := parseSavedPlaylist(playlist)
+	if !ok {
+		return true
+	}
+	if btDB == nil || userID == 0 {
+		return false
+	}
+
+	var exists int
+	err := btDB.QueryRow(`SELECT 1 FROM blindtest_playlists WHERE id = ? AND (owner_id = ? OR shared = 1)`, id, userID).Scan(&exists)
+	return err == nil
+}
+
+func fetchPlaylistPool(ctx context.Context, playlist string, limit int) ([]Track, error) {
+	id, ok := parseSavedPlaylist(playlist)
+	if !ok {
+		return fetchTracksFromDeezer(ctx, playlist, limit)
+	}
+	if btDB == nil {
+		return nil, errPlaylistNotFound
+	}
+
+	tracks, err := fetchPlaylistTracks(id)
+	if err != nil {
+		return nil, err
+	}
+	return shuffleAndLimit(tracks, limit), nil
+}
+
+func handlePlaylists(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var id int64
+	if raw := r.URL.Query().Get("id"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "invalid playlist id", http.StatusBadRequest)
+			return
+		}
+		id = parsed
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if id == 0 {
+			playlists, err := fetchPlaylists(user.ID)
+			if err != nil {
+				log.Println("BlindTest: playlists:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			respondJSON(w, map[string]interface{}{"playlists": playlists})
+			return
+		}
+		playlist, err := fetchPlaylist(id, user.ID)
+		if respondPlaylistError(w, err) {
+			return
+		}
+		respondJSON(w, playlist)
+
+	case http.MethodPost, http.MethodPut:
+		var req playlistRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if len([]rune(req.Name)) > maxPlaylistName {
+			http.Error(w, "name too long", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPost && req.Name == "" {
+			http.Error(w, "name required", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPut && id == 0 {
+			http.Error(w, "playlist id required", http.StatusBadRequest)
+			return
+		}
+
+		var tracks []Track
+		if req.hasTracks() {
+			ctx, cancel := context.WithTimeout(r.Context(), playlistTimeout)
+			defer cancel()
+
+			var err error
+			tracks, err = resolvePlaylistTracks(ctx, req)
+			if err != nil {
+				log.Println("BlindTest: playlist import:", err)
+				http.Error(w, "could not import tracks", http.StatusBadGateway)
+				return
+			}
+			if tracks == nil {
+				tracks = []Track{}
+			}
+		}
+
+		status := http.StatusOK
+		if r.Method == http.MethodPost {
+			shared := req.Shared != nil && *req.Shared
+			created, err := createPlaylist(user.ID, req.Name, shared, tracks)
+			if err != nil {
+				log.Println("BlindTest: create playlist:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			id = created
+			status = http.StatusCreated
+		} else if respondPlaylistError(w, updatePlaylist(id, user.ID, req.Name, req.Shared, tracks)) {
+			return
+		}
+
+		playlist, err := fetchPlaylist(id, user.ID)
+		if respondPlaylistError(w, err) {
+			return
+		}
+		respondJSONStatus(w, status, playlist)
+
+	case http.MethodDelete:
+		if id == 0 {
+			http.Error(w, "playlist id required", http.StatusBadRequest)
+			return
+		}
+		if respondPlaylistError(w, deletePlaylist(id, user.ID)) {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func respondPlaylistError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, errPlaylistNotFound):
+		http.Error(w, "playlist not found", http.StatusNotFound)
+	default:
+		log.Println("BlindTest: playlist:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+	}
+	return true
+}
+
+func handleTrackSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		respondJSON(w, map[string]interface{}{"tracks": []Track{}})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), playlistTimeout)
+	defer cancel()
+
+	tracks, err := deezer.searchTracks(ctx, query, trackSearchLimit)
+	if err != nil {
+		log.Println("BlindTest: track search:", err)
+		http.Error(w, "search unavailable", http.StatusBadGateway)
+		return
+	}
+	respondJSON(w, map[string]interface{}{"tracks": tracks})
+}
@@ -0,0 +1,337 @@
+package blindtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	recipePlaylist      = "recipe"
+	maxRecipeParts      = 8
+	maxRecipeArtists    = 20
+	maxRecipeWeight     = 10
+	recipeArtistTracks  = 10
+	recipeChartTracks   = 100
+	recipeSearchLists   = 3
+	recipeDatedFactor   = 4
+	recipeMinDatedCheck = 20
+	minRecipeYear       = 1900
+)
+
+type PlaylistRecipe struct {
+	Parts []RecipePart `json:"parts"`
+}
+
+type RecipePart struct {
+	Genre    string   `json:"genre,omitempty"`
+	Artists  []string `json:"artists,omitempty"`
+	Decade   int      `json:"decade,omitempty"`
+	FromYear int      `json:"fromYear,omitempty"`
+	ToYear   int      `json:"toYear,omitempty"`
+	Weight   float64  `json:"weight,omitempty"`
+}
+
+type recipePartReport struct {
+	Label     string `json:"label"`
+	Requested int    `json:"requested"`
+	Found     int    `json:"found"`
+}
+
+type recipeReport struct {
+	Parts     []recipePartReport `json:"parts"`
+	Requested int                `json:"requested"`
+	Found     int                `json:"found"`
+	Filled    bool               `json:"filled"`
+}
+
+func normalizeRecipe(recipe *PlaylistRecipe) *PlaylistRecipe {
+	if recipe == nil {
+		return nil
+	}
+
+	currentYear := time.Now().Year()
+	normalized := &PlaylistRecipe{}
+	for _, part := range recipe.Parts {
+		if len(normalized.Parts) >= maxRecipeParts {
+			break
+		}
+
+		part.Genre = strings.TrimSpace(part.Genre)
+		if getGenreID(part.Genre) == 0 {
+			part.Genre = ""
+		}
+
+		artists := make([]string, 0, len(part.Artists))
+		for _, a := range part.Artists {
+			if a = strings.TrimSpace(a); a != "" && len(artists) < maxRecipeArtists {
+				artists = append(artists, a)
+			}
+		}
+		part.Artists = artists
+
+		if part.Decade >= minRecipeYear && part.Decade <= currentYear {
+			part.Decade -= part.Decade % 10
+			part.FromYear, part.ToYear = part.Decade, part.Decade+9
+		} else {
+			part.Decade = 0
+		}
+		if part.FromYear < minRecipeYear || part.FromYear > currentYear {
+			part.FromYear = 0
+		}
+		if part.ToYear < minRecipeYear || part.ToYear > currentYear+1 {
+			part.ToYear = 0
+		}
+		if part.FromYear != 0 && part.ToYear != 0 && part.ToYear < part.FromYear {
+			part.FromYear, part.ToYear = part.ToYear, part.FromYear
+		}
+
+		if part.Weight <= 0 {
+			part.Weight = 1
+		}
+		part.Weight = math.Min(part.Weight, maxRecipeWeight)
+
+		if part.Genre == "" && len(part.Artists) == 0 && part.FromYear == 0 && part.ToYear == 0 {
+			continue
+		}
+		normalized.Parts = append(normalized.Parts, part)
+	}
+
+	if len(normalized.Parts) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+func (p RecipePart) dated() bool {
+	return p.FromYear != 0 || p.ToYear != 0
+}
+
+func (p RecipePart) inRange(track Track) bool {
+	year := track.Year()
+	if year == 0 {
+		return false
+	}
+	return (p.FromYear == 0 || year >= p.FromYear) && (p.ToYear == 0 || year <= p.ToYear)
+}
+
+func (p RecipePart) label() string {
+	var parts []string
+	if p.Genre != "" {
+		parts = append(parts, p.Genre)
+	}
+	if len(p.Artists) > 0 {
+		parts = append(parts, strings.Join(p.Artists, ", "))
+	}
+	switch {
+	case p.Decade != 0:
+		parts = append(parts, fmt.Sprintf("%ds", p.Decade))
+	case p.FromYear != 0 && p.ToYear != 0:
+		parts = append(parts, fmt.Sprintf("%d-%d", p.FromYear, p.ToYear))
+	case p.FromYear != 0:
+		parts = append(parts, fmt.Sprintf("%d+", p.FromYear))
+	case p.ToYear != 0:
+		parts = append(parts, fmt.Sprintf("-%d", p.ToYear))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (p RecipePart) searchQuery() string {
+	query := p.Genre
+	if query == "" {
+		query = "hits"
+	}
+	if p.Decade != 0 {
+		return fmt.Sprintf("%s %ds", query, p.Decade%100)
+	}
+	year := p.FromYear
+	if year == 0 {
+		year = p.ToYear
+	}
+	return fmt.Sprintf("%s %d", query, year)
+}
+
+func resolveRecipePart(ctx context.Context, part RecipePart, quota int) ([]Track, error) {
+	var (
+		tracks []Track
+		err    error
+	)
+	switch {
+	case len(part.Artists) > 0:
+		tracks, err = deezer.fanOut(ctx, part.Artists, func(ctx context.Context, artist string) ([]Track, error) {
+			return deezer.searchTracks(ctx, fmt.Sprintf("artist:%q", artist), recipeArtistTracks)
+		})
+	case part.dated():
+		var lists []int64
+		lists, err = deezer.searchPlaylists(ctx, part.searchQuery(), recipeSearchLists)
+		if err == nil {
+			tracks, err = deezer.fanOut(ctx, playlistKeys(lists), func(ctx context.Context, key string) ([]Track, error) {
+				id, err := strconv.ParseInt(key, 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				return deezer.playlistTracks(ctx, id, recipeChartTracks)
+			})
+		}
+	default:
+		tracks, err = deezer.chartTracks(ctx, getGenreID(part.Genre), recipeChartTracks)
+	}
+	if err != nil || !part.dated() {
+		return tracks, err
+	}
+
+	rand.Shuffle(len(tracks), func(i, j int) {
+		tracks[i], tracks[j] = tracks[j], tracks[i]
+	})
+	check := max(quota*recipeDatedFactor, recipeMinDatedCheck)
+	if len(tracks) > check {
+		tracks = tracks[:check]
+	}
+	deezer.fillReleaseDates(ctx, tracks)
+
+	dated := tracks[:0]
+	for _, t := range tracks {
+		if part.inRange(t) {
+			dated = append(dated, t)
+		}
+	}
+	return dated, nil
+}
+
+func playlistKeys(ids []int64) []string {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = strconv.FormatInt(id, 10)
+	}
+	return keys
+}
+
+func recipeQuotas(parts []RecipePart, total int) []int {
+	weights := 0.0
+	for _, p := range parts {
+		weights += p.Weight
+	}
+
+	quotas := make([]int, len(parts))
+	remainders := make([]float64, len(parts))
+	assigned := 0
+	for i, p := range parts {
+		exact := float64(total) * p.Weight / weights
+		quotas[i] = int(exact)
+		remainders[i] = exact - float64(quotas[i])
+		assigned += quotas[i]
+	}
+	for ; assigned < total; assigned++ {
+		best := 0
+		for i := range remainders {
+			if remainders[i] > remainders[best] {
+				best = i
+			}
+		}
+		quotas[best]++
+		remainders[best] = -1
+	}
+	return quotas
+}
+
+func buildRecipe(ctx context.Context, recipe *PlaylistRecipe, rounds, limit int, selection trackSelection) ([]Track, *recipeReport, error) {
+	parts := recipe.Parts
+	quotas := recipeQuotas(parts, rounds)
+	heard := selection.heard()
+
+	pools := make([][]Track, len(parts))
+	errs := deezer.parallel(ctx, len(parts), func(ctx context.Context, i int) error {
+		tracks, err := resolveRecipePart(ctx, parts[i], quotas[i])
+		if err != nil {
+			return fmt.Errorf("%s: %w", parts[i].label(), err)
+		}
+		tracks = applyDifficulty(tracks, selection.difficulty, 2*quotas[i])
+		pools[i] = selectTracks(tracks, len(tracks), heard)
+		return nil
+	})
+	if len(errs) > 0 {
+		log.Printf("BlindTest: %d/%d recipe parts failed: %v", len(errs), len(parts), errors.Join(errs...))
+	}
+
+	report := &recipeReport{Requested: rounds}
+	for i, p := range parts {
+		report.Parts = append(report.Parts, recipePartReport{Label: p.label(), Requested: quotas[i]})
+	}
+
+	usedIDs := make(map[int64]bool)
+	usedSongs := make(map[string]bool)
+	take := func(i int) (Track, bool) {
+		for len(pools[i]) > 0 {
+			t := pools[i][0]
+			pools[i] = pools[i][1:]
+			key := songKey(t)
+			if usedIDs[t.ID] || (key != "" && usedSongs[key]) {
+				continue
+			}
+			usedIDs[t.ID] = true
+			usedSongs[key] = true
+			return t, true
+		}
+		return Track{}, false
+	}
+
+	var selected []Track
+	for i := range parts {
+		for report.Parts[i].Found < quotas[i] {
+			t, ok := take(i)
+			if !ok {
+				break
+			}
+			selected = append(selected, t)
+			report.Parts[i].Found++
+		}
+	}
+	report.Filled = true
+	for i := range parts {
+		if report.Parts[i].Found < quotas[i] {
+			report.Filled = false
+		}
+	}
+	for progress := true; len(selected) < rounds && progress; {
+		progress = false
+		for i := range parts {
+			if len(selected) >= rounds {
+				break
+			}
+			if t, ok := take(i); ok {
+				selected = append(selected, t)
+				report.Parts[i].Found++
+				progress = true
+			}
+		}
+	}
+
+	rand.Shuffle(len(selected), func(i, j int) {
+		selected[i], selected[j] = selected[j], selected[i]
+	})
+	report.Found = len(selected)
+
+	for progress := true; len(selected) < limit && progress; {
+		progress = false
+		for i := range parts {
+			if len(selected) >= limit {
+				break
+			}
+			if t, ok := take(i); ok {
+				selected = append(selected, t)
+				progress = true
+			}
+		}
+	}
+
+	if len(selected) == 0 && len(errs) > 0 {
+		return nil, report, errors.Join(errs...)
+	}
+	return selected, report, nil
+}
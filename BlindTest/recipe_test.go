@@ -0,0 +1,131 @@
+package blindtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestRecipeQuotas(t *testing.T) {
+	tests := []struct {
+		weights []float64
+		total   int
+		want    []int
+	}{
+		{[]float64{1}, 10, []int{10}},
+		{[]float64{3, 1}, 8, []int{6, 2}},
+		{[]float64{1, 1}, 3, []int{2, 1}},
+		{[]float64{1, 1, 1}, 10, []int{4, 3, 3}},
+		{[]float64{1, 1, 1}, 2, []int{1, 1, 0}},
+		{[]float64{2.5, 1}, 7, []int{5, 2}},
+		{[]float64{1, 3}, 5, []int{1, 4}},
+		{[]float64{1, 2}, 0, []int{0, 0}},
+	}
+	for _, tt := range tests {
+		parts := make([]RecipePart, len(tt.weights))
+		for i, w := range tt.weights {
+			parts[i].Weight = w
+		}
+		got := recipeQuotas(parts, tt.total)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("recipeQuotas(%v, %d) = %v, want %v", tt.weights, tt.total, got, tt.want)
+		}
+	}
+}
+
+func recipeTrack(id int64, title, artist string) deezerTrack {
+	var track deezerTrack
+	track.ID = id
+	track.Title = title
+	track.Preview = fmt.Sprintf("https://example.invalid/%d.mp3", id)
+	track.Rank = 1000
+	track.Artist.Name = artist
+	return track
+}
+
+func serveRecipeStub(responses map[string][]deezerTrack) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path
+		if q := r.URL.Query().Get("q"); q != "" {
+			key += "?" + q
+		}
+		json.NewEncoder(w).Encode(deezerTrackList{Data: responses[key]})
+	}
+}
+
+func TestBuildRecipe(t *testing.T) {
+	var rock []deezerTrack
+	for i := 1; i <= 20; i++ {
+		rock = append(rock, recipeTrack(int64(i), fmt.Sprintf("Rock song %d", i), fmt.Sprintf("Rock band %d", i)))
+	}
+
+	tests := []struct {
+		name      string
+		rare      []deezerTrack
+		rounds    int
+		wantParts []int
+		wantFound int
+		filled    bool
+	}{
+		{
+			name:      "filled",
+			rare:      []deezerTrack{recipeTrack(101, "Rare one", "Rare"), recipeTrack(102, "Rare two", "Rare")},
+			rounds:    8,
+			wantParts: []int{6, 2},
+			wantFound: 8,
+			filled:    true,
+		},
+		{
+			name:      "unfilled part is backfilled",
+			rare:      []deezerTrack{recipeTrack(101, "Rare one", "Rare")},
+			rounds:    8,
+			wantParts: []int{7, 1},
+			wantFound: 8,
+		},
+		{
+			name: "versions are de-duplicated across parts and artists",
+			rare: []deezerTrack{
+				recipeTrack(101, "Rock song 1 (Live)", "Rare"),
+				recipeTrack(102, "Rock song 2 - Remastered 2011", "Rare"),
+				recipeTrack(103, "Rare one", "Rare"),
+			},
+			rounds:    22,
+			wantFound: 21,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useStubDeezer(t, serveRecipeStub(map[string][]deezerTrack{
+				"/chart/152/tracks":                           rock,
+				"/search?" + fmt.Sprintf("artist:%q", "Rare"): tt.rare,
+			}))
+			recipe := normalizeRecipe(&PlaylistRecipe{Parts: []RecipePart{
+				{Genre: "rock", Weight: 3},
+				{Artists: []string{"Rare"}, Weight: 1},
+			}})
+
+			tracks, report, err := buildRecipe(context.Background(), recipe, tt.rounds, tt.rounds, trackSelection{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Found != tt.wantFound || len(tracks) != tt.wantFound || report.Filled != tt.filled {
+				t.Errorf("found %d (%d tracks), filled %v; want %d, filled %v", report.Found, len(tracks), report.Filled, tt.wantFound, tt.filled)
+			}
+			for i, part := range report.Parts {
+				if tt.wantParts != nil && part.Found != tt.wantParts[i] {
+					t.Errorf("part %s found %d, want %d", part.Label, part.Found, tt.wantParts[i])
+				}
+			}
+
+			songs := make(map[string]bool)
+			for _, track := range tracks {
+				if songs[songKey(track)] {
+					t.Errorf("%q selected twice", track.Title)
+				}
+				songs[songKey(track)] = true
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package blindtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"groupie-tracker/wsclient"
+)
+
+const reconnectGrace = 60 * time.Second
+
+func generateReconnectToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return generateRoomCode() + generateRoomCode()
+	}
+	return hex.EncodeToString(b)
+}
+
+func handlePlayerDisconnect(room *Room, player *Player, client *wsclient.Client) {
+	if room.Players[player.ID] != player || player.Client != client {
+		return
+	}
+	player.Connected = false
+	player.DisconnectedAt = time.Now()
+	if migrateHost(room) {
+		broadcastHost(room)
+	}
+
+	broadcastPlayerList(room)
+
+	time.AfterFunc(reconnectGrace, func() {
+		sendCommand(room, expireCommand{player: player})
+	})
+}
+
+func expirePlayer(room *Room, player *Player) {
+	expired := room.Players[player.ID] == player && !player.Connected &&
+		time.Since(player.DisconnectedAt) >= reconnectGrace
+
+	if expired {
+		removePlayer(room, player)
+	}
+}
+
+func rejoinPlayer(room *Room, token string, client *wsclient.Client) *Player {
+	if token == "" {
+		return nil
+	}
+
+	for _, p := range room.Players {
+		if p.Token != token {
+			continue
+		}
+		old := p.Client
+		p.Client = client
+		p.Connected = true
+		if old != nil && old != client {
+			old.Close()
+		}
+
+		client.Send(rejoinState(room, p))
+		if migrateHost(room) {
+			broadcastHost(room)
+		}
+		broadcastPlayerList(room)
+		return p
+	}
+	return nil
+}
+
+func rejoinState(room *Room, player *Player) Message {
+	data := map[string]interface{}{
+		"playerId":    player.ID,
+		"token":       player.Token,
+		"hostId":      room.HostID,
+		"settings":    roomSettings(room),
+		"gameStarted": inGame(room),
+		"phase":       room.Phase,
+		"score":       player.Score,
+		"team":        player.Team,
+		"ready":       player.Ready,
+	}
+
+	if round := roundState(room); round != nil {
+		if answer := room.PlayerAnswers[player.ID]; answer != nil {
+			round["foundTitle"] = answer.FoundTitle
+			round["foundArtist"] = answer.FoundArtist
+			round["foundAlbum"] = answer.FoundAlbum
+			round["remainingGuesses"] = room.MaxGuesses - answer.Guesses
+			round["choice"] = answer.Choice
+			round["yearGuess"] = answer.YearGuess
+		}
+		round["completed"] = room.CorrectAnswers[player.ID]
+		data["round"] = round
+	}
+
+	return Message{
+		Type:   "rejoined",
+		RoomID: room.ID,
+		Data:   data,
+	}
+}
+
+func roundState(room *Room) map[string]interface{} {
+	if !inGame(room) || room.CurrentTrack == nil {
+		return nil
+	}
+
+	elapsed := time.Since(room.RoundStartTime)
+	if room.Paused {
+		elapsed = room.PausedAt.Sub(room.RoundStartTime)
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	round := map[string]interface{}{
+		"round":     room.RoundNumber,
+		"maxRounds": room.MaxRounds,
+		"mode":      room.Mode,
+		"preview":   audioURL(room.AudioToken),
+		"elapsed":   elapsed.Seconds(),
+		"playAt":    serverMillis(room.RoundStartTime),
+		"roundTime": room.RoundTime,
+		"remaining": timeRemaining(room).Seconds(),
+		"phase":     room.Phase,
+		"paused":    room.Paused,
+	}
+	if room.Mode == modeChoice {
+		round["options"] = room.Options
+	}
+	return round
+}
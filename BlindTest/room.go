@@ -1,28 +1,98 @@
 package blindtest
 
-import "math/rand"
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"groupie-tracker/wsclient"
+)
+
+func createRoom(msg Message) *Room {
+	playlist := msg.Playlist
+	if playlist == "" {
+		playlist = "pop"
+	}
 
-func createRoom(maxRounds, roundTime int, playlist string) *Room {
 	roomID := generateRoomCode()
+	ctx, cancel := context.WithCancel(context.Background())
 	room := &Room{
 		ID:              roomID,
 		Players:         make(map[string]*Player),
-		GameStarted:     false,
+		Phase:           phaseLobby,
 		CorrectAnswers:  make(map[string]bool),
 		PlayerAnswers:   make(map[string]*PlayerAnswer),
 		CurrentTrackIdx: 0,
-		MaxRounds:       maxRounds,
-		RoundTime:       roundTime,
+		MaxRounds:       clampMaxRounds(msg.MaxRounds),
+		RoundTime:       clampRoundTime(msg.RoundTime),
+		Intermission:    clampIntermission(msg.Intermission),
+		SkipVotes:       make(map[string]bool),
+		Disputes:        make(map[string]*Dispute),
+		PlayedTracks:    make(map[int64]bool),
 		Playlist:        playlist,
+		Mode:            normalizeMode(msg.Mode),
+		Scoring:         normalizeScoring(msg.Scoring),
+		Difficulty:      normalizeDifficulty(msg.Difficulty),
+		Recipe:          normalizeRecipe(msg.Recipe),
+		MinCoverage:     defaultMinCoverage,
+		MaxGuesses:      defaultMaxGuesses,
+		Banned:          make(map[string]bool),
+		Spectators:      make(map[string]*Player),
+		commands:        make(chan command, commandBuffer),
+		done:            make(chan struct{}),
+		ctx:             ctx,
+		cancel:          cancel,
+		lastActivity:    time.Now(),
+	}
+	if room.Recipe != nil {
+		room.Playlist = recipePlaylist
 	}
+	if msg.MinCoverage > 0 && msg.MinCoverage <= 1 {
+		room.MinCoverage = msg.MinCoverage
+	}
+	if msg.MaxGuesses > 0 && msg.MaxGuesses <= 50 {
+		room.MaxGuesses = msg.MaxGuesses
+	}
+	setupTeams(room, msg.TeamCount, msg.Aggregation)
 
 	roomsMu.Lock()
 	rooms[roomID] = room
 	roomsMu.Unlock()
 
+	roomEvent(room, "room_created")
+	go runRoom(room)
 	return room
 }
 
+func lookupRoom(roomID string) *Room {
+	roomsMu.RLock()
+	defer roomsMu.RUnlock()
+	return rooms[roomID]
+}
+
+func clampMaxRounds(maxRounds int) int {
+	if maxRounds <= 0 || maxRounds > 20 {
+		return 10
+	}
+	return maxRounds
+}
+
+func clampRoundTime(roundTime int) int {
+	if roundTime < 10 || roundTime > 60 {
+		return 30
+	}
+	return roundTime
+}
+
+func broadcast(room *Room, msg Message) {
+	for _, player := range room.Players {
+		player.Client.Send(msg)
+	}
+	for _, spectator := range room.Spectators {
+		spectator.Client.Send(msg)
+	}
+}
+
 func generateRoomCode() string {
 	const letters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	b := make([]byte, 6)
@@ -33,47 +103,141 @@ func generateRoomCode() string {
 }
 
 func broadcastPlayerList(room *Room) {
-	room.mu.RLock()
-	defer room.mu.RUnlock()
-
 	players := make([]map[string]interface{}, 0)
 	for _, p := range room.Players {
 		players = append(players, map[string]interface{}{
-			"id":       p.ID,
-			"username": p.Username,
-			"score":    p.Score,
-			"ready":    p.Ready,
+			"id":        p.ID,
+			"username":  p.Username,
+			"score":     p.Score,
+			"ready":     p.Ready,
+			"team":      p.Team,
+			"host":      p.ID == room.HostID,
+			"connected": p.Connected,
 		})
 	}
 
 	msg := Message{
 		Type: "player_list",
 		Data: map[string]interface{}{
-			"players": players,
+			"hostId":     room.HostID,
+			"players":    players,
+			"spectators": spectatorList(room),
+			"teams":      teamStandings(room),
 		},
 	}
 
-	for _, player := range room.Players {
-		player.Conn.WriteJSON(msg)
+	broadcast(room, msg)
+}
+
+func handleJoin(room *Room, cmd joinCommand) bool {
+	player := cmd.player
+	if room.Banned[banKey(player)] {
+		sendError(player.Client, "You have been banned from this room")
+		return false
 	}
+
+	if cmd.spectate || inGame(room) {
+		player.Spectator = true
+		room.Spectators[player.ID] = player
+
+		data := map[string]interface{}{
+			"spectatorId": player.ID,
+			"hostId":      room.HostID,
+			"settings":    roomSettings(room),
+			"gameStarted": inGame(room),
+			"phase":       room.Phase,
+		}
+		if round := roundState(room); round != nil {
+			data["round"] = round
+		}
+		player.Client.Send(Message{
+			Type:   "spectator_joined",
+			RoomID: room.ID,
+			Data:   data,
+		})
+		broadcastPlayerList(room)
+		return true
+	}
+
+	player.Token = generateReconnectToken()
+	player.Connected = true
+	room.Players[player.ID] = player
+	assignTeam(room, player)
+	if room.HostID == "" {
+		room.HostID = player.ID
+	} else if migrateHost(room) {
+		broadcastHost(room)
+	}
+
+	msgType := "room_joined"
+	if cmd.create {
+		msgType = "room_created"
+	}
+	player.Client.Send(Message{
+		Type:   msgType,
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"playerId": player.ID,
+			"token":    player.Token,
+			"hostId":   room.HostID,
+			"settings": roomSettings(room),
+		},
+	})
+	broadcastPlayerList(room)
+	return true
+}
+
+func handleLeave(room *Room, player *Player, client *wsclient.Client) {
+	if room.Spectators[player.ID] == player {
+		removeSpectator(room, player)
+		return
+	}
+	handlePlayerDisconnect(room, player, client)
 }
 
-func handlePlayerDisconnect(room *Room, player *Player) {
-	room.mu.Lock()
+func handleReady(room *Room, player *Player) {
+	if room.Players[player.ID] != player || room.Phase != phaseLobby {
+		return
+	}
+	player.Ready = true
+	broadcastPlayerList(room)
+	checkAllReady(room)
+}
+
+func checkAllReady(room *Room) {
+	if room.Phase != phaseLobby {
+		return
+	}
+
+	active := 0
+	for _, p := range room.Players {
+		if !p.Connected {
+			continue
+		}
+		active++
+		if !p.Ready {
+			return
+		}
+	}
+	if active == 0 {
+		return
+	}
+	startGame(room)
+}
+
+func removePlayer(room *Room, player *Player) {
 	delete(room.Players, player.ID)
-	room.mu.Unlock()
+	if migrateHost(room) {
+		broadcastHost(room)
+	}
 
-	if !room.GameStarted {
+	if !inGame(room) {
 		broadcastPlayerList(room)
 	}
 
-	room.mu.RLock()
-	playerCount := len(room.Players)
-	room.mu.RUnlock()
-
-	if playerCount == 0 {
-		roomsMu.Lock()
-		delete(rooms, room.ID)
-		roomsMu.Unlock()
+	if len(room.Players) == 0 {
+		closeRoom(room, "empty")
+		return
 	}
+	checkAllReady(room)
 }
@@ -0,0 +1,273 @@
+package blindtest
+
+import (
+	"time"
+
+	"groupie-tracker/wsclient"
+)
+
+const commandBuffer = 64
+
+type command interface{}
+
+type joinCommand struct {
+	player   *Player
+	create   bool
+	spectate bool
+	joined   chan bool
+}
+
+type leaveCommand struct {
+	player *Player
+	client *wsclient.Client
+}
+
+type rejoinCommand struct {
+	token  string
+	client *wsclient.Client
+	player chan *Player
+}
+
+type readyCommand struct {
+	player *Player
+}
+
+type answerCommand struct {
+	player *Player
+	answer string
+}
+
+type teamCommand struct {
+	player *Player
+	team   string
+}
+
+type playCommand struct {
+	player *Player
+}
+
+type hostCommand struct {
+	player *Player
+	msg    Message
+}
+
+type voteCommand struct {
+	player *Player
+}
+
+type disputeCommand struct {
+	player  *Player
+	guessID int
+	field   string
+}
+
+type disputeVoteCommand struct {
+	player    *Player
+	disputeID string
+	accept    bool
+}
+
+type expireCommand struct {
+	player *Player
+}
+
+type tracksCommand struct {
+	tracks []Track
+	report *recipeReport
+	err    error
+}
+
+type tickCommand struct {
+	seq int
+}
+
+func runRoom(room *Room) {
+	defer close(room.done)
+
+	for !room.closed {
+		select {
+		case cmd := <-room.commands:
+			handleCommand(room, cmd)
+		case <-room.ctx.Done():
+			closeRoom(room, "cancelled")
+		}
+	}
+}
+
+func sendCommand(room *Room, cmd command) bool {
+	select {
+	case room.commands <- cmd:
+		return true
+	case <-room.done:
+		return false
+	}
+}
+
+func handleCommand(room *Room, cmd command) {
+	switch cmd.(type) {
+	case tickCommand, roundTickCommand, expireCommand, tracksCommand, sweepCommand:
+	default:
+		room.lastActivity = time.Now()
+	}
+
+	switch c := cmd.(type) {
+	case joinCommand:
+		c.joined <- handleJoin(room, c)
+	case leaveCommand:
+		handleLeave(room, c.player, c.client)
+		checkAllReady(room)
+		checkRoundComplete(room)
+	case rejoinCommand:
+		c.player <- rejoinPlayer(room, c.token, c.client)
+	case readyCommand:
+		if guardPhase(room, c.player, "ready") {
+			handleReady(room, c.player)
+		}
+	case answerCommand:
+		if guardPhase(room, c.player, "answer") {
+			handleAnswer(room, c.player, c.answer)
+			checkRoundComplete(room)
+		}
+	case teamCommand:
+		if guardPhase(room, c.player, "choose_team") && chooseTeam(room, c.player, c.team) {
+			broadcastPlayerList(room)
+		}
+	case playCommand:
+		if !guardPhase(room, c.player, "join_as_player") {
+			return
+		}
+		if errMsg := requestPlay(room, c.player); errMsg != "" {
+			sendError(c.player.Client, errMsg)
+			return
+		}
+		broadcastPlayerList(room)
+	case hostCommand:
+		if guardPhase(room, c.player, c.msg.Type) {
+			handleHostCommand(room, c.player, c.msg)
+		}
+	case playbackCommand:
+		if guardPhase(room, c.player, "playback_started") {
+			recordPlayback(room, c.player, c.startedAt, c.received)
+		}
+	case voteCommand:
+		if guardPhase(room, c.player, "vote_skip") {
+			voteSkip(room, c.player)
+		}
+	case disputeCommand:
+		if guardPhase(room, c.player, "dispute") {
+			openDispute(room, c.player, c.guessID, c.field)
+		}
+	case disputeVoteCommand:
+		if guardPhase(room, c.player, "dispute_vote") {
+			voteDispute(room, c.player, c.disputeID, c.accept)
+		}
+	case expireCommand:
+		expirePlayer(room, c.player)
+	case tracksCommand:
+		handleTracksLoaded(room, c.tracks, c.report, c.err)
+	case tickCommand:
+		fireTimer(room, c.seq)
+	case roundTickCommand:
+		handleRoundTick(room)
+	case sweepCommand:
+		sweepRoom(room, c.now)
+	}
+}
+
+func joinRoom(room *Room, player *Player, create, spectate bool) bool {
+	joined := make(chan bool, 1)
+	cmd := joinCommand{player: player, create: create, spectate: spectate, joined: joined}
+	if sendCommand(room, cmd) {
+		select {
+		case ok := <-joined:
+			return ok
+		case <-room.done:
+		}
+	}
+	sendError(player.Client, "Room not found")
+	return false
+}
+
+func rejoinRoom(room *Room, token string, client *wsclient.Client) *Player {
+	reply := make(chan *Player, 1)
+	if !sendCommand(room, rejoinCommand{token: token, client: client, player: reply}) {
+		return nil
+	}
+	select {
+	case player := <-reply:
+		return player
+	case <-room.done:
+		return nil
+	}
+}
+
+func schedule(room *Room, d time.Duration, next func(*Room)) {
+	cancelTimer(room)
+
+	seq := room.timerSeq
+	room.timerNext = next
+	room.timerDeadline = time.Now().Add(d)
+	room.timer = time.AfterFunc(d, func() {
+		sendCommand(room, tickCommand{seq: seq})
+	})
+}
+
+func cancelTimer(room *Room) {
+	if room.timer != nil {
+		room.timer.Stop()
+		room.timer = nil
+	}
+	room.timerNext = nil
+	room.timerSeq++
+}
+
+func pauseTimer(room *Room) {
+	next := room.timerNext
+	if next == nil {
+		return
+	}
+	remaining := time.Until(room.timerDeadline)
+	cancelTimer(room)
+	room.timerNext = next
+	room.timerRemaining = remaining
+}
+
+func resumeTimer(room *Room) {
+	if room.timerNext != nil && room.timer == nil {
+		schedule(room, room.timerRemaining, room.timerNext)
+	}
+}
+
+func fireTimer(room *Room, seq int) {
+	if seq != room.timerSeq || room.timer == nil {
+		return
+	}
+	next := room.timerNext
+	room.timer = nil
+	room.timerNext = nil
+	next(room)
+}
+
+func guardPhase(room *Room, player *Player, msgType string) bool {
+	if allowedMessage(room, msgType) {
+		return true
+	}
+	player.Client.Send(Message{
+		Type: "error",
+		Data: map[string]interface{}{
+			"message": "Not allowed during the " + room.Phase + " phase",
+			"code":    "wrong_phase",
+			"phase":   room.Phase,
+		},
+	})
+	return false
+}
+
+func sendError(client *wsclient.Client, message string) {
+	client.Send(Message{
+		Type: "error",
+		Data: map[string]interface{}{
+			"message": message,
+		},
+	})
+}
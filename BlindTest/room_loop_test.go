@@ -0,0 +1,244 @@
+package blindtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	loadPlayers    = 6
+	loadRounds     = 3
+	stubTrackCount = 10
+)
+
+func stubTrack(baseURL string, i int) deezerTrack {
+	words := []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliet"}
+	var track deezerTrack
+	track.ID = int64(i + 1)
+	track.Title = "Song " + words[i]
+	track.Preview = fmt.Sprintf("%s/preview/%d.mp3", baseURL, track.ID)
+	track.Duration = 30
+	track.Rank = 1000 * (i + 1)
+	track.Artist.ID = int64(100 + i)
+	track.Artist.Name = "Band " + words[i]
+	track.Album.ID = int64(200 + i)
+	track.Album.Title = "Record " + words[i]
+	return track
+}
+
+func serveStubDeezer(w http.ResponseWriter, r *http.Request) {
+	baseURL := "http://" + r.Host
+	writeJSON := func(v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+
+	var id int64
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/chart/"):
+		list := deezerTrackList{}
+		for i := 0; i < stubTrackCount; i++ {
+			list.Data = append(list.Data, stubTrack(baseURL, i))
+		}
+		writeJSON(list)
+	case strings.HasPrefix(r.URL.Path, "/album/"):
+		writeJSON(map[string]interface{}{
+			"release_date": "2001-01-01",
+			"cover_big":    baseURL + "/cover.jpg",
+		})
+	case r.URL.Path == "/cover.jpg":
+		w.Write([]byte("\xff\xd8\xff stub cover"))
+	default:
+		if _, err := fmt.Sscanf(r.URL.Path, "/track/%d", &id); err == nil && id >= 1 && id <= stubTrackCount {
+			writeJSON(stubTrack(baseURL, int(id-1)))
+			return
+		}
+		if _, err := fmt.Sscanf(r.URL.Path, "/preview/%d.mp3", &id); err == nil {
+			fmt.Fprintf(w, "ID3 stub preview %d", id)
+			return
+		}
+		http.NotFound(w, r)
+	}
+}
+
+func stubAnswer(t *testing.T, serverURL, preview string) string {
+	resp, err := http.Get(serverURL + preview)
+	if err != nil {
+		t.Errorf("fetch preview: %v", err)
+		return ""
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	var id int
+	if _, err := fmt.Sscanf(string(body), "ID3 stub preview %d", &id); err != nil || id < 1 || id > stubTrackCount {
+		t.Errorf("unexpected preview %q", body)
+		return ""
+	}
+	track := stubTrack("", id-1)
+	return track.Title + " " + track.Artist.Name
+}
+
+type loadClient struct {
+	conn     *websocket.Conn
+	mu       sync.Mutex
+	messages chan Message
+}
+
+func dialLoadClient(t *testing.T, url string) *loadClient {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &loadClient{conn: conn, messages: make(chan Message, 256)}
+	go func() {
+		defer close(c.messages)
+		for {
+			var msg Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+func (c *loadClient) send(t *testing.T, msg map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.conn.WriteJSON(msg); err != nil {
+		t.Errorf("send %v: %v", msg["type"], err)
+	}
+}
+
+func (c *loadClient) waitFor(t *testing.T, msgType string) Message {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				t.Fatalf("connection closed while waiting for %s", msgType)
+			}
+			if msg.Type == msgType {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", msgType)
+		}
+	}
+}
+
+func TestRoomLoopLoad(t *testing.T) {
+	useStubDeezer(t, serveStubDeezer)
+	prevPreviews, prevCovers := previews, covers
+	previews = newPreviewCache(t.TempDir())
+	covers = newCoverCache(t.TempDir())
+	t.Cleanup(func() {
+		previews, covers = prevPreviews, prevCovers
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blindtest/ws", handleWebSocket)
+	mux.HandleFunc(audioPath, handleAudio)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/blindtest/ws"
+
+	host := dialLoadClient(t, url)
+	host.send(t, map[string]interface{}{
+		"type":         "create_room",
+		"username":     "player0",
+		"playlist":     "pop",
+		"maxRounds":    loadRounds,
+		"roundTime":    10,
+		"intermission": minIntermission,
+	})
+	roomID := host.waitFor(t, "room_created").RoomID
+	if roomID == "" {
+		t.Fatal("room_created without a room id")
+	}
+
+	clients := []*loadClient{host}
+	for i := 1; i < loadPlayers; i++ {
+		c := dialLoadClient(t, url)
+		c.send(t, map[string]interface{}{
+			"type":     "join_room",
+			"roomId":   roomID,
+			"username": fmt.Sprintf("player%d", i),
+		})
+		c.waitFor(t, "room_joined")
+		clients = append(clients, c)
+	}
+
+	dropped := clients[len(clients)-1]
+
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		wg.Add(1)
+		go func(i int, c *loadClient) {
+			defer wg.Done()
+			c.send(t, map[string]interface{}{"type": "ready"})
+
+			for {
+				select {
+				case msg, ok := <-c.messages:
+					if !ok {
+						if c != dropped {
+							t.Errorf("player%d: connection closed before game_end", i)
+						}
+						return
+					}
+					switch msg.Type {
+					case "round_start":
+						if c == dropped {
+							c.conn.Close()
+							continue
+						}
+						c.send(t, map[string]interface{}{"type": "clock_sync", "clientTime": time.Now().UnixMilli()})
+						c.send(t, map[string]interface{}{"type": "answer", "answer": "definitely not it"})
+						preview, _ := msg.Data["preview"].(string)
+						c.send(t, map[string]interface{}{"type": "answer", "answer": stubAnswer(t, server.URL, preview)})
+					case "round_end":
+						c.send(t, map[string]interface{}{"type": "vote_skip"})
+					case "game_end":
+						c.conn.Close()
+						checkFinalScore(t, msg, fmt.Sprintf("player%d", i))
+						return
+					}
+				case <-time.After(30 * time.Second):
+					t.Errorf("player%d: timed out before game_end", i)
+					c.conn.Close()
+					return
+				}
+			}
+		}(i, c)
+	}
+	wg.Wait()
+}
+
+func checkFinalScore(t *testing.T, msg Message, username string) {
+	players, _ := msg.Data["players"].([]interface{})
+	for _, raw := range players {
+		p, _ := raw.(map[string]interface{})
+		if p["username"] != username {
+			continue
+		}
+		if score, _ := p["score"].(float64); score <= 0 {
+			t.Errorf("%s finished with score %v", username, p["score"])
+		}
+		return
+	}
+	t.Errorf("%s missing from game_end", username)
+}
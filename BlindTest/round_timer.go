@@ -0,0 +1,160 @@
+package blindtest
+
+import "time"
+
+const (
+	tickInterval        = time.Second
+	defaultIntermission = 5
+	minIntermission     = 3
+	maxIntermission     = 30
+)
+
+type roundTickCommand struct{}
+
+func clampIntermission(seconds int) int {
+	if seconds < minIntermission || seconds > maxIntermission {
+		return defaultIntermission
+	}
+	return seconds
+}
+
+func startTicker(room *Room) {
+	stopTicker(room)
+
+	stop := make(chan struct{})
+	room.tickStop = stop
+	go func() {
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case room.commands <- roundTickCommand{}:
+				case <-stop:
+					return
+				case <-room.done:
+					return
+				}
+			case <-stop:
+				return
+			case <-room.done:
+				return
+			}
+		}
+	}()
+}
+
+func stopTicker(room *Room) {
+	if room.tickStop != nil {
+		close(room.tickStop)
+		room.tickStop = nil
+	}
+}
+
+func timeRemaining(room *Room) time.Duration {
+	remaining := time.Until(room.timerDeadline)
+	if room.Paused {
+		remaining = room.timerRemaining
+	}
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+func handleRoundTick(room *Room) {
+	if room.Phase != phasePlaying && room.Phase != phaseReveal {
+		return
+	}
+
+	broadcast(room, Message{
+		Type: "round_tick",
+		Data: map[string]interface{}{
+			"phase":     room.Phase,
+			"round":     room.RoundNumber,
+			"remaining": timeRemaining(room).Seconds(),
+			"paused":    room.Paused,
+		},
+	})
+}
+
+func playerDone(room *Room, player *Player) bool {
+	if room.CorrectAnswers[player.ID] {
+		return true
+	}
+	answer := room.PlayerAnswers[player.ID]
+	if answer == nil {
+		return false
+	}
+
+	switch room.Mode {
+	case modeYear:
+		return answer.YearGuess != 0
+	case modeChoice:
+		return answer.Choice != ""
+	}
+	return answer.Guesses >= room.MaxGuesses
+}
+
+func checkRoundComplete(room *Room) {
+	if room.Phase != phasePlaying {
+		return
+	}
+
+	active := 0
+	for _, p := range room.Players {
+		if !p.Connected {
+			continue
+		}
+		active++
+		if !playerDone(room, p) {
+			return
+		}
+	}
+	if active == 0 {
+		return
+	}
+
+	cancelTimer(room)
+	endRound(room)
+}
+
+func activePlayerCount(room *Room) int {
+	count := 0
+	for _, p := range room.Players {
+		if p.Connected {
+			count++
+		}
+	}
+	return count
+}
+
+func voteSkip(room *Room, player *Player) {
+	if room.Phase != phaseReveal || room.Players[player.ID] != player {
+		return
+	}
+	room.SkipVotes[player.ID] = true
+
+	needed := activePlayerCount(room)/2 + 1
+	votes := 0
+	for id := range room.SkipVotes {
+		if p, ok := room.Players[id]; ok && p.Connected {
+			votes++
+		}
+	}
+
+	broadcast(room, Message{
+		Type: "skip_votes",
+		Data: map[string]interface{}{
+			"votes":  votes,
+			"needed": needed,
+		},
+	})
+
+	if votes >= needed {
+		cancelTimer(room)
+		nextRound(room)
+	}
+}
@@ -1,18 +1,69 @@
 package blindtest
 
-import "net/http"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var userResolver func(*http.Request) (*UserInfo, error)
 
 func serveHome(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "static/index.html")
 }
 
-func RegisterRoutes(authMiddleware func(http.HandlerFunc) http.HandlerFunc) {
+func RegisterRoutes(
+	authMiddleware func(http.HandlerFunc) http.HandlerFunc,
+	resolver func(*http.Request) (*UserInfo, error),
+) error {
+	userResolver = resolver
+
+	if err := initBlindTestStore(); err != nil {
+		return fmt.Errorf("initialisation base BlindTest: %w", err)
+	}
+
 	http.HandleFunc("/BlindTest", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "BlindTest/static/index.html")
 	}))
 
 	http.HandleFunc("/blindtest/ws", handleWebSocket)
+	http.HandleFunc(audioPath, handleAudio)
+	http.HandleFunc(coverPath, authMiddleware(handleCover))
+	http.HandleFunc("/api/blindtest/metrics", authMiddleware(handleMetrics))
+	http.HandleFunc("/api/blindtest/history", authMiddleware(handleHistory))
+	http.HandleFunc("/api/blindtest/playlists", authMiddleware(handlePlaylists))
+	http.HandleFunc("/api/blindtest/tracks/search", authMiddleware(handleTrackSearch))
+	http.HandleFunc("/api/blindtest/aliases", authMiddleware(handleAliases))
+	http.HandleFunc("/api/blindtest/aliases/import", authMiddleware(handleAliasImport))
 
 	fs := http.FileServer(http.Dir("BlindTest/static"))
 	http.Handle("/blindtest/static/", http.StripPrefix("/blindtest/static/", fs))
+
+	go previews.warm()
+	go covers.warm()
+	go runJanitor(janitorInterval)
+	return nil
+}
+
+func currentUser(r *http.Request) *UserInfo {
+	if userResolver == nil {
+		return nil
+	}
+	user, err := userResolver(r)
+	if err != nil || user == nil {
+		return nil
+	}
+	user.Pseudo = strings.TrimSpace(user.Pseudo)
+	return user
+}
+
+func respondJSON(w http.ResponseWriter, data interface{}) {
+	respondJSONStatus(w, http.StatusOK, data)
+}
+
+func respondJSONStatus(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
 }
@@ -0,0 +1,143 @@
+package blindtest
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	curveStep        = "step"
+	curveLinear      = "linear"
+	curveExponential = "exponential"
+
+	maxPoints          = 1000
+	minPoints          = 100
+	exponentialFalloff = 3.0
+	maxFirstBonus      = 1000
+	maxStreakBonus     = 2.0
+)
+
+type ScoringPolicy struct {
+	Curve        string  `json:"curve"`
+	PartialRatio float64 `json:"partialRatio"`
+	FirstBonus   int     `json:"firstBonus"`
+	StreakStep   float64 `json:"streakStep"`
+	StreakMax    float64 `json:"streakMax"`
+}
+
+type pointsBreakdown struct {
+	Base       int     `json:"base"`
+	Ratio      float64 `json:"ratio"`
+	FirstBonus int     `json:"firstBonus"`
+	Streak     int     `json:"streak"`
+	Multiplier float64 `json:"multiplier"`
+	Difficulty float64 `json:"difficulty"`
+	Total      int     `json:"total"`
+}
+
+func defaultScoring() ScoringPolicy {
+	return ScoringPolicy{
+		Curve:        curveStep,
+		PartialRatio: 0.5,
+	}
+}
+
+func normalizeScoring(policy *ScoringPolicy) ScoringPolicy {
+	scoring := defaultScoring()
+	if policy == nil {
+		return scoring
+	}
+
+	switch policy.Curve {
+	case curveLinear, curveExponential:
+		scoring.Curve = policy.Curve
+	}
+	if policy.PartialRatio > 0 && policy.PartialRatio <= 1 {
+		scoring.PartialRatio = policy.PartialRatio
+	}
+	if policy.FirstBonus > 0 && policy.FirstBonus <= maxFirstBonus {
+		scoring.FirstBonus = policy.FirstBonus
+	}
+	if policy.StreakStep > 0 && policy.StreakStep <= 1 {
+		scoring.StreakStep = policy.StreakStep
+		scoring.StreakMax = 1 + 5*policy.StreakStep
+		if policy.StreakMax > 1 && policy.StreakMax <= maxStreakBonus {
+			scoring.StreakMax = policy.StreakMax
+		}
+		if scoring.StreakMax > maxStreakBonus {
+			scoring.StreakMax = maxStreakBonus
+		}
+	}
+	return scoring
+}
+
+func basePoints(policy ScoringPolicy, elapsed, roundTime float64) int {
+	if policy.Curve != curveLinear && policy.Curve != curveExponential {
+		return calculatePoints(elapsed)
+	}
+	if roundTime <= 0 || elapsed >= roundTime {
+		return minPoints
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	decay := 1 - elapsed/roundTime
+	if policy.Curve == curveExponential {
+		decay = math.Exp(-exponentialFalloff * elapsed / roundTime)
+	}
+	return minPoints + int(float64(maxPoints-minPoints)*decay)
+}
+
+func streakMultiplier(policy ScoringPolicy, streak int) float64 {
+	if policy.StreakStep <= 0 || streak <= 0 {
+		return 1
+	}
+	return math.Min(1+policy.StreakStep*float64(streak), policy.StreakMax)
+}
+
+func scoreAnswer(room *Room, player *Player, partial bool, at time.Time) pointsBreakdown {
+	elapsed := listeningElapsed(room, player, at).Seconds()
+	breakdown := pointsBreakdown{
+		Base:  basePoints(room.Scoring, elapsed, float64(room.RoundTime)),
+		Ratio: 1,
+	}
+	if partial {
+		breakdown.Ratio = room.Scoring.PartialRatio
+	}
+
+	points := float64(breakdown.Base) * breakdown.Ratio
+	if !partial && room.FirstFinder == "" {
+		room.FirstFinder = player.ID
+		breakdown.FirstBonus = room.Scoring.FirstBonus
+		points += float64(breakdown.FirstBonus)
+	}
+
+	return applyStreak(room, player, breakdown, points)
+}
+
+func applyStreak(room *Room, player *Player, breakdown pointsBreakdown, points float64) pointsBreakdown {
+	breakdown.Streak = player.Streak
+	breakdown.Multiplier = 1
+	if points > 0 {
+		breakdown.Multiplier = streakMultiplier(room.Scoring, player.Streak)
+	}
+	breakdown.Difficulty = difficultyMultiplier(room.Difficulty)
+	breakdown.Total = int(math.Round(points * breakdown.Multiplier * breakdown.Difficulty))
+	return breakdown
+}
+
+func updateStreaks(room *Room) {
+	for _, p := range room.Players {
+		updateStreak(room, p)
+	}
+}
+
+func updateStreak(room *Room, player *Player) {
+	player.PrevStreak = player.Streak
+	if room.RoundPoints[player.ID] > 0 {
+		player.Streak++
+	} else {
+		player.Streak = 0
+	}
+}
@@ -0,0 +1,104 @@
+package blindtest
+
+import "testing"
+
+func TestNormalizeScoring(t *testing.T) {
+	tests := []struct {
+		name string
+		in   *ScoringPolicy
+		want ScoringPolicy
+	}{
+		{"nil", nil, ScoringPolicy{Curve: curveStep, PartialRatio: 0.5}},
+		{"unknown curve", &ScoringPolicy{Curve: "cubic"}, ScoringPolicy{Curve: curveStep, PartialRatio: 0.5}},
+		{"linear", &ScoringPolicy{Curve: curveLinear, PartialRatio: 0.25}, ScoringPolicy{Curve: curveLinear, PartialRatio: 0.25}},
+		{"ratio out of range", &ScoringPolicy{PartialRatio: 1.5}, ScoringPolicy{Curve: curveStep, PartialRatio: 0.5}},
+		{"first bonus", &ScoringPolicy{FirstBonus: 200}, ScoringPolicy{Curve: curveStep, PartialRatio: 0.5, FirstBonus: 200}},
+		{"first bonus too high", &ScoringPolicy{FirstBonus: maxFirstBonus + 1}, ScoringPolicy{Curve: curveStep, PartialRatio: 0.5}},
+		{"streak default max", &ScoringPolicy{StreakStep: 0.1}, ScoringPolicy{Curve: curveStep, PartialRatio: 0.5, StreakStep: 0.1, StreakMax: 1.5}},
+		{"streak custom max", &ScoringPolicy{StreakStep: 0.1, StreakMax: 1.2}, ScoringPolicy{Curve: curveStep, PartialRatio: 0.5, StreakStep: 0.1, StreakMax: 1.2}},
+		{"streak max capped", &ScoringPolicy{StreakStep: 0.5}, ScoringPolicy{Curve: curveStep, PartialRatio: 0.5, StreakStep: 0.5, StreakMax: maxStreakBonus}},
+		{"streak max without step", &ScoringPolicy{StreakMax: 1.5}, ScoringPolicy{Curve: curveStep, PartialRatio: 0.5}},
+	}
+	for _, tt := range tests {
+		if got := normalizeScoring(tt.in); got != tt.want {
+			t.Errorf("%s: normalizeScoring = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBasePoints(t *testing.T) {
+	tests := []struct {
+		curve   string
+		elapsed float64
+		want    int
+	}{
+		{curveStep, 0, 1000},
+		{curveStep, 7, 800},
+		{curveStep, 29, 100},
+		{curveLinear, 0, maxPoints},
+		{curveLinear, -1, maxPoints},
+		{curveLinear, 15, 550},
+		{curveLinear, 30, minPoints},
+		{curveLinear, 45, minPoints},
+		{curveExponential, 0, maxPoints},
+		{curveExponential, 10, 431},
+		{curveExponential, 30, minPoints},
+	}
+	for _, tt := range tests {
+		got := basePoints(ScoringPolicy{Curve: tt.curve}, tt.elapsed, 30)
+		if got != tt.want {
+			t.Errorf("basePoints(%s, %v) = %d, want %d", tt.curve, tt.elapsed, got, tt.want)
+		}
+	}
+	if got := basePoints(ScoringPolicy{Curve: curveLinear}, 5, 0); got != minPoints {
+		t.Errorf("basePoints without a round time = %d, want %d", got, minPoints)
+	}
+}
+
+func TestStreakMultiplier(t *testing.T) {
+	policy := ScoringPolicy{StreakStep: 0.1, StreakMax: 1.3}
+	tests := []struct {
+		policy ScoringPolicy
+		streak int
+		want   float64
+	}{
+		{ScoringPolicy{}, 5, 1},
+		{policy, 0, 1},
+		{policy, -1, 1},
+		{policy, 1, 1.1},
+		{policy, 2, 1.2},
+		{policy, 10, 1.3},
+	}
+	for _, tt := range tests {
+		got := streakMultiplier(tt.policy, tt.streak)
+		if got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("streakMultiplier(%+v, %d) = %v, want %v", tt.policy, tt.streak, got, tt.want)
+		}
+	}
+}
+
+func TestApplyStreak(t *testing.T) {
+	tests := []struct {
+		difficulty string
+		streak     int
+		points     float64
+		want       int
+	}{
+		{difficultyMedium, 0, 800, 800},
+		{difficultyMedium, 2, 800, 960},
+		{difficultyHard, 0, 800, 1200},
+		{difficultyEasy, 2, 500, 480},
+		{difficultyMedium, 3, 0, 0},
+	}
+	for _, tt := range tests {
+		room := &Room{
+			Scoring:    ScoringPolicy{StreakStep: 0.1, StreakMax: 1.5},
+			Difficulty: tt.difficulty,
+		}
+		player := &Player{Streak: tt.streak}
+		got := applyStreak(room, player, pointsBreakdown{}, tt.points)
+		if got.Total != tt.want || got.Streak != tt.streak {
+			t.Errorf("applyStreak(%s, streak %d, %v) = %+v, want total %d", tt.difficulty, tt.streak, tt.points, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package blindtest
+
+import (
+	"log"
+	"strings"
+)
+
+const (
+	selectionPoolFactor = 3
+	recentTrackWindow   = "-30 days"
+)
+
+type trackSelection struct {
+	difficulty string
+	recipe     *PlaylistRecipe
+	userIDs    []int
+	played     map[int64]bool
+}
+
+func newTrackSelection(room *Room) trackSelection {
+	selection := trackSelection{
+		difficulty: room.Difficulty,
+		recipe:     room.Recipe,
+		played:     make(map[int64]bool, len(room.PlayedTracks)),
+	}
+	for id := range room.PlayedTracks {
+		selection.played[id] = true
+	}
+	for _, p := range room.Players {
+		if p.UserID != 0 {
+			selection.userIDs = append(selection.userIDs, p.UserID)
+		}
+	}
+	return selection
+}
+
+func (s trackSelection) heard() map[int64]bool {
+	heard := make(map[int64]bool, len(s.played))
+	for id := range s.played {
+		heard[id] = true
+	}
+
+	recent, err := fetchRecentTracks(s.userIDs)
+	if err != nil {
+		log.Println("BlindTest: recent tracks:", err)
+	}
+	for id := range recent {
+		heard[id] = true
+	}
+	return heard
+}
+
+func songKey(track Track) string {
+	return compact(normalizeAnswer(cleanTarget(track.Title)))
+}
+
+func artistKey(track Track) string {
+	return compact(normalizeAnswer(track.Artist))
+}
+
+func selectTracks(pool []Track, limit int, heard map[int64]bool) []Track {
+	seenIDs := make(map[int64]bool, len(pool))
+	seenSongs := make(map[string]bool, len(pool))
+	var fresh, stale []Track
+
+	for _, t := range pool {
+		key := songKey(t)
+		if seenIDs[t.ID] || (key != "" && seenSongs[key]) {
+			continue
+		}
+		seenIDs[t.ID] = true
+		seenSongs[key] = true
+
+		if heard[t.ID] {
+			stale = append(stale, t)
+		} else {
+			fresh = append(fresh, t)
+		}
+	}
+
+	selected := append(fresh, stale...)
+	if len(selected) > limit {
+		selected = selected[:limit]
+	}
+	return selected
+}
+
+func spreadArtists(tracks []Track) []Track {
+	queues := make(map[string][]Track)
+	var order []string
+	for _, t := range tracks {
+		key := artistKey(t)
+		if _, ok := queues[key]; !ok {
+			order = append(order, key)
+		}
+		queues[key] = append(queues[key], t)
+	}
+
+	spread := make([]Track, 0, len(tracks))
+	last := ""
+	for len(spread) < len(tracks) {
+		pick := ""
+		for _, key := range order {
+			if len(queues[key]) == 0 || (key == last && len(order) > 1) {
+				continue
+			}
+			if pick == "" || len(queues[key]) > len(queues[pick]) {
+				pick = key
+			}
+		}
+		if pick == "" {
+			pick = last
+		}
+
+		spread = append(spread, queues[pick][0])
+		queues[pick] = queues[pick][1:]
+		last = pick
+	}
+	return spread
+}
+
+func fetchRecentTracks(userIDs []int) (map[int64]bool, error) {
+	recent := make(map[int64]bool)
+	if btDB == nil || len(userIDs) == 0 {
+		return recent, nil
+	}
+
+	args := make([]interface{}, 0, len(userIDs)+1)
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+	args = append(args, recentTrackWindow)
+
+	rows, err := btDB.Query(`SELECT DISTINCT r.track_id
+		FROM blindtest_rounds r
+		JOIN blindtest_round_results rr ON rr.game_id = r.game_id AND rr.round_number = r.round_number
+		JOIN blindtest_games g ON g.id = r.game_id
+		WHERE rr.user_id IN (?`+strings.Repeat(", ?", len(userIDs)-1)+`)
+		AND g.started_at >= datetime('now', ?)`, args...)
+	if err != nil {
+		return recent, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return recent, err
+		}
+		recent[id] = true
+	}
+	return recent, rows.Err()
+}
@@ -0,0 +1,83 @@
+package blindtest
+
+import "testing"
+
+func TestSelectTracks(t *testing.T) {
+	pool := []Track{
+		{ID: 1, Title: "Bohemian Rhapsody - Remastered 2011", Artist: "Queen"},
+		{ID: 2, Title: "Bohemian Rhapsody (Live)", Artist: "Queen"},
+		{ID: 3, Title: "Bohemian Rhapsody", Artist: "Panic! At The Disco"},
+		{ID: 1, Title: "Bohemian Rhapsody - Remastered 2011", Artist: "Queen"},
+		{ID: 4, Title: "Hey Jude", Artist: "The Beatles"},
+		{ID: 5, Title: "Let It Be", Artist: "The Beatles"},
+		{ID: 6, Title: "Hallelujah", Artist: "Leonard Cohen"},
+	}
+	tests := []struct {
+		name  string
+		limit int
+		heard map[int64]bool
+		want  []int64
+	}{
+		{"dedups ids and versions across artists", 10, nil, []int64{1, 4, 5, 6}},
+		{"limit", 2, nil, []int64{1, 4}},
+		{"heard tracks go last", 10, map[int64]bool{1: true, 5: true}, []int64{4, 6, 1, 5}},
+		{"heard tracks are dropped first", 3, map[int64]bool{4: true}, []int64{1, 5, 6}},
+	}
+	for _, tt := range tests {
+		got := selectTracks(pool, tt.limit, tt.heard)
+		var ids []int64
+		for _, track := range got {
+			ids = append(ids, track.ID)
+		}
+		if len(ids) != len(tt.want) {
+			t.Errorf("%s: selectTracks = %v, want %v", tt.name, ids, tt.want)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.want[i] {
+				t.Errorf("%s: selectTracks = %v, want %v", tt.name, ids, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSpreadArtists(t *testing.T) {
+	tests := []struct {
+		name     string
+		artists  []string
+		adjacent int
+	}{
+		{"empty", nil, 0},
+		{"single artist", []string{"A", "A", "A"}, 2},
+		{"already spread", []string{"A", "B", "A", "B"}, 0},
+		{"grouped", []string{"A", "A", "A", "B", "B", "C"}, 0},
+		{"folded names", []string{"The Beatles", "beatles", "Queen", "Queen", "ABBA", "Abba"}, 0},
+		{"too many from one artist", []string{"A", "A", "A", "A", "B"}, 2},
+	}
+	for _, tt := range tests {
+		tracks := make([]Track, len(tt.artists))
+		for i, artist := range tt.artists {
+			tracks[i] = Track{ID: int64(i + 1), Artist: artist}
+		}
+
+		got := spreadArtists(tracks)
+		if len(got) != len(tracks) {
+			t.Fatalf("%s: spreadArtists returned %d tracks, want %d", tt.name, len(got), len(tracks))
+		}
+		seen := make(map[int64]bool)
+		adjacent := 0
+		for i, track := range got {
+			if seen[track.ID] {
+				t.Errorf("%s: track %d returned twice", tt.name, track.ID)
+			}
+			seen[track.ID] = true
+			if i > 0 && artistKey(track) == artistKey(got[i-1]) {
+				adjacent++
+			}
+		}
+		if adjacent != tt.adjacent {
+			t.Errorf("%s: %d back-to-back artists, want %d", tt.name, adjacent, tt.adjacent)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package blindtest
+
+import (
+	"sort"
+	"time"
+)
+
+func removeSpectator(room *Room, spectator *Player) {
+	delete(room.Spectators, spectator.ID)
+
+	broadcastPlayerList(room)
+}
+
+func requestPlay(room *Room, spectator *Player) string {
+	if _, ok := room.Spectators[spectator.ID]; !ok {
+		return ""
+	}
+	if !room.AllowSpectatorJoin && room.HostID != "" {
+		return "The host does not allow spectators to join"
+	}
+	if room.Banned[banKey(spectator)] {
+		return "You have been banned from this room"
+	}
+
+	if inGame(room) {
+		spectator.WantsToPlay = true
+		return ""
+	}
+
+	promoteSpectator(room, spectator)
+	return ""
+}
+
+func promoteSpectator(room *Room, spectator *Player) {
+	delete(room.Spectators, spectator.ID)
+	spectator.Spectator = false
+	spectator.WantsToPlay = false
+	spectator.Score = 0
+	spectator.Ready = false
+	spectator.JoinedAt = time.Now()
+	spectator.Connected = true
+	spectator.Token = generateReconnectToken()
+	room.Players[spectator.ID] = spectator
+	assignTeam(room, spectator)
+	if room.HostID == "" {
+		room.HostID = spectator.ID
+	}
+
+	spectator.Client.Send(Message{
+		Type:   "room_joined",
+		RoomID: room.ID,
+		Data: map[string]interface{}{
+			"playerId": spectator.ID,
+			"token":    spectator.Token,
+			"hostId":   room.HostID,
+			"settings": roomSettings(room),
+		},
+	})
+}
+
+func promoteWaitingSpectators(room *Room) {
+	if !room.AllowSpectatorJoin {
+		return
+	}
+	for _, s := range room.Spectators {
+		if s.WantsToPlay {
+			promoteSpectator(room, s)
+		}
+	}
+}
+
+func spectatorList(room *Room) []map[string]interface{} {
+	spectators := make([]map[string]interface{}, 0, len(room.Spectators))
+	for _, s := range room.Spectators {
+		spectators = append(spectators, map[string]interface{}{
+			"id":          s.ID,
+			"username":    s.Username,
+			"wantsToPlay": s.WantsToPlay,
+		})
+	}
+	sort.Slice(spectators, func(i, j int) bool {
+		return spectators[i]["username"].(string) < spectators[j]["username"].(string)
+	})
+	return spectators
+}
@@ -0,0 +1,45 @@
+package blindtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpectatorJoinsMidRound(t *testing.T) {
+	room := testRoom(t, modeClassic, &Track{ID: 1, Title: "Hey Jude", Artist: "The Beatles"})
+	room.RoundNumber = 2
+	room.RoundTime = 30
+	room.AudioToken = "token"
+	room.timerDeadline = time.Now().Add(20 * time.Second)
+	host := testPlayer(t, room, "p0")
+	room.HostID = host.ID
+
+	client, messages := testClient(t)
+	spectator := &Player{ID: "s1", Username: "s1", Client: client}
+	if !handleJoin(room, joinCommand{player: spectator, spectate: true}) {
+		t.Fatal("join failed")
+	}
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-messages:
+			if msg.Type != "spectator_joined" {
+				continue
+			}
+			round, _ := msg.Data["round"].(map[string]interface{})
+			if round == nil {
+				t.Fatalf("spectator_joined without the current round: %v", msg.Data)
+			}
+			if round["preview"] != audioURL(room.AudioToken) || round["round"] != float64(2) {
+				t.Errorf("round = %v", round)
+			}
+			if remaining, _ := round["remaining"].(float64); remaining <= 0 {
+				t.Errorf("remaining = %v, want the time left in the round", round["remaining"])
+			}
+			return
+		case <-timeout:
+			t.Fatal("no spectator_joined message")
+		}
+	}
+}
@@ -5,10 +5,55 @@ let username = '';
 let gameTimer = null;
 let timerDuration = 30;
 let audio = null;
+let currentMode = 'classic';
+let teamNamesById = {};
+let hostId = null;
+let intentionalClose = false;
+let isSpectator = false;
+let reconnectAttempts = 0;
+let timerPaused = false;
+let currentPhase = 'lobby';
+let timerElapsed = 0;
+let clockOffset = 0;
+let bestClockRtt = Infinity;
+let clockSyncTimer = null;
+let playTimeout = null;
+let playbackAcked = true;
+let savedPlaylists = [];
+let builderTracks = [];
+let rejectedGuesses = [];
 let lastGameConfig = {
     playlist: 'generale',
+    mode: 'classic',
+    teams: 0,
+    aggregation: 'sum',
+    difficulty: 'medium',
+    recipe: null,
+    scoring: 'step',
+    bonus: false,
     rounds: 5,
-    time: 30
+    time: 30,
+    intermission: 5
+};
+
+const difficultyLabels = {
+    easy: 'Facile',
+    medium: 'Moyen',
+    hard: 'Difficile'
+};
+
+const disputeFieldLabels = {
+    title: 'titre',
+    artist: 'artiste',
+    album: 'album'
+};
+
+const modePlaceholders = {
+    classic: 'Titre ou artiste...',
+    title: 'Titre de la chanson...',
+    artist: "Nom de l'artiste...",
+    album: "Nom de l'album...",
+    year: 'Année de sortie (ex: 1998)...'
 };
 
 const screens = {
@@ -23,11 +68,72 @@ const screens = {
 document.addEventListener('DOMContentLoaded', () => {
     setupEventListeners();
     audio = document.getElementById('audio-player');
+    audio.addEventListener('playing', acknowledgePlayback);
+
+    if (loadSession()) {
+        rejoinRoom();
+    }
 });
 
+function saveSession(token) {
+    sessionStorage.setItem('blindtest_session', JSON.stringify({
+        roomId: currentRoomId,
+        token: token,
+        username: username
+    }));
+}
+
+function loadSession() {
+    try {
+        return JSON.parse(sessionStorage.getItem('blindtest_session'));
+    } catch (e) {
+        return null;
+    }
+}
+
+function clearSession() {
+    sessionStorage.removeItem('blindtest_session');
+}
+
+function rejoinRoom() {
+    const session = loadSession();
+    if (!session) {
+        return;
+    }
+
+    username = session.username;
+    connectWebSocket();
+
+    ws.onopen = () => {
+        ws.send(JSON.stringify({
+            type: 'rejoin',
+            roomId: session.roomId,
+            token: session.token
+        }));
+    };
+}
+
+function scheduleReconnect() {
+    if (reconnectAttempts >= 10 || !loadSession()) {
+        clearSession();
+        showScreen('home');
+        return;
+    }
+
+    reconnectAttempts++;
+    setTimeout(rejoinRoom, Math.min(1000 * reconnectAttempts, 5000));
+}
+
 function setupEventListeners() {
     document.getElementById('create-room-btn').addEventListener('click', showConfigScreen);
-    document.getElementById('join-room-btn').addEventListener('click', joinRoom);
+    document.getElementById('join-room-btn').addEventListener('click', () => joinRoom(false));
+    document.getElementById('spectate-room-btn').addEventListener('click', () => joinRoom(true));
+    document.getElementById('join-as-player-btn').addEventListener('click', () => {
+        if (ws && ws.readyState === WebSocket.OPEN) {
+            ws.send(JSON.stringify({ type: 'join_as_player' }));
+            document.getElementById('join-as-player-btn').disabled = true;
+        }
+    });
     document.getElementById('confirm-config-btn').addEventListener('click', createRoom);
     document.getElementById('cancel-config-btn').addEventListener('click', () => showScreen('home'));
     document.getElementById('ready-btn').addEventListener('click', setReady);
@@ -41,9 +147,95 @@ function setupEventListeners() {
     });
     document.getElementById('back-home-btn').addEventListener('click', backToHome);
     document.getElementById('replay-btn').addEventListener('click', replayGame);
+    document.getElementById('vote-skip-btn').addEventListener('click', voteSkip);
+    document.getElementById('start-now-btn').addEventListener('click', () => sendHostCommand('start_now'));
+    document.getElementById('pause-btn').addEventListener('click', togglePause);
+    document.getElementById('skip-btn').addEventListener('click', () => sendHostCommand('skip'));
+    document.getElementById('apply-settings-btn').addEventListener('click', applySettings);
+    document.getElementById('lobby-playlist-select').innerHTML = document.getElementById('playlist-select').innerHTML;
+    document.getElementById('builder-search-input').addEventListener('keypress', (e) => {
+        if (e.key === 'Enter') {
+            searchTracks();
+        }
+    });
+    document.getElementById('builder-save-btn').addEventListener('click', savePlaylist);
+    document.getElementById('add-recipe-part-btn').addEventListener('click', addRecipePart);
+    loadSavedPlaylists();
+}
+
+function isHost() {
+    return currentPlayerId !== null && hostId === currentPlayerId;
+}
+
+function sendHostCommand(type, extra = {}) {
+    if (ws && ws.readyState === WebSocket.OPEN) {
+        ws.send(JSON.stringify({ type: type, ...extra }));
+    }
+}
+
+function togglePause() {
+    sendHostCommand(timerPaused ? 'resume' : 'pause');
+}
+
+function applySettings() {
+    sendHostCommand('update_settings', {
+        allowSpectatorJoin: document.getElementById('allow-spectators-input').checked,
+        playlist: document.getElementById('lobby-playlist-select').value,
+        maxRounds: parseInt(document.getElementById('lobby-rounds-input').value),
+        roundTime: parseInt(document.getElementById('lobby-time-input').value),
+        intermission: parseInt(document.getElementById('lobby-intermission-input').value),
+        difficulty: document.getElementById('lobby-difficulty-select').value
+    });
+}
+
+function updateSpectatorView() {
+    document.getElementById('ready-btn').classList.toggle('hidden', isSpectator);
+    document.getElementById('join-as-player-btn').classList.toggle('hidden', !isSpectator);
+    document.getElementById('join-as-player-btn').disabled = false;
+    document.getElementById('spectator-banner').classList.toggle('hidden', !isSpectator);
+    document.getElementById('answer-section').classList.toggle('hidden', isSpectator);
+    document.getElementById('choices-container').classList.toggle('hidden', isSpectator);
+}
+
+function updateSpectators(spectators) {
+    const container = document.getElementById('spectators-container');
+    container.innerHTML = '';
+    spectators.forEach(spectator => {
+        const div = document.createElement('div');
+        div.className = 'player-item';
+        div.textContent = spectator.wantsToPlay ? `${spectator.username} (veut jouer)` : spectator.username;
+        container.appendChild(div);
+    });
+}
+
+function updateHostControls() {
+    document.getElementById('host-controls').classList.toggle('active', isHost());
+    document.getElementById('host-game-controls').classList.toggle('active', isHost());
+}
+
+function updateSettingsDisplay(settings) {
+    if (!settings) {
+        return;
+    }
+    timerDuration = settings.roundTime;
+    document.getElementById('lobby-playlist-select').value = settings.playlist;
+    document.getElementById('lobby-rounds-input').value = settings.maxRounds;
+    document.getElementById('lobby-time-input').value = settings.roundTime;
+    document.getElementById('lobby-intermission-input').value = settings.intermission;
+    document.getElementById('lobby-difficulty-select').value = settings.difficulty || 'medium';
+    document.getElementById('allow-spectators-input').checked = !!settings.allowSpectatorJoin;
+    const playlistSelect = document.getElementById('lobby-playlist-select');
+    let playlistName = playlistSelect.selectedIndex >= 0 ? playlistSelect.options[playlistSelect.selectedIndex].text : settings.playlist;
+    if (settings.recipe) {
+        playlistName = 'Recette personnalisée';
+    }
+    document.getElementById('lobby-settings').textContent =
+        `Playlist: ${playlistName} • ${settings.maxRounds} manches • ${settings.roundTime}s • ${difficultyLabels[settings.difficulty] || difficultyLabels.medium}`;
 }
 
 function backToHome() {
+    clearSession();
+    intentionalClose = true;
     if (ws) {
         ws.close();
         ws = null;
@@ -52,6 +244,17 @@ function backToHome() {
 }
 
 function replayGame() {
+    if (currentRoomId && currentPhase === 'finished') {
+        if (isHost()) {
+            sendHostCommand('play_again');
+        } else {
+            showInfoNotification("⏳ En attente de l'hôte...");
+        }
+        return;
+    }
+
+    clearSession();
+    intentionalClose = true;
     if (ws) {
         ws.close();
         ws = null;
@@ -63,8 +266,15 @@ function replayGame() {
     document.getElementById('ready-btn').textContent = 'Prêt !';
     
     document.getElementById('playlist-select').value = lastGameConfig.playlist;
+    document.getElementById('mode-select').value = lastGameConfig.mode;
+    document.getElementById('teams-select').value = lastGameConfig.teams;
+    document.getElementById('aggregation-select').value = lastGameConfig.aggregation;
+    document.getElementById('difficulty-select').value = lastGameConfig.difficulty;
+    document.getElementById('scoring-select').value = lastGameConfig.scoring;
+    document.getElementById('bonus-input').checked = lastGameConfig.bonus;
     document.getElementById('rounds-input').value = lastGameConfig.rounds;
     document.getElementById('time-input').value = lastGameConfig.time;
+    document.getElementById('intermission-input').value = lastGameConfig.intermission;
     updateGenreDescription();
     
     timerDuration = lastGameConfig.time;
@@ -76,8 +286,15 @@ function replayGame() {
             type: 'create_room',
             username: username,
             playlist: lastGameConfig.playlist,
+            mode: lastGameConfig.mode,
+            teamCount: lastGameConfig.teams,
+            teamAggregation: lastGameConfig.aggregation,
+            difficulty: lastGameConfig.difficulty,
+            recipe: lastGameConfig.recipe,
+            scoring: scoringPolicy(lastGameConfig.scoring, lastGameConfig.bonus),
             maxRounds: lastGameConfig.rounds,
-            roundTime: lastGameConfig.time
+            roundTime: lastGameConfig.time,
+            intermission: lastGameConfig.intermission
         }));
     };
 }
@@ -87,6 +304,7 @@ function connectWebSocket() {
     const wsUrl = `${protocol}//${window.location.host}/blindtest/ws`; 
     
     ws = new WebSocket(wsUrl);
+    intentionalClose = false;
 
     ws.onopen = () => {
         console.log('WebSocket connected');
@@ -103,6 +321,9 @@ function connectWebSocket() {
 
     ws.onclose = () => {
         console.log('WebSocket disconnected');
+        if (!intentionalClose && currentRoomId) {
+            scheduleReconnect();
+        }
     };
 }
 
@@ -110,27 +331,126 @@ function handleMessage(message) {
     switch (message.type) {
         case 'room_created':
             currentRoomId = message.roomId;
+            currentPhase = 'lobby';
             currentPlayerId = message.data.playerId;
+            hostId = message.data.hostId;
+            updateHostControls();
+            updateSettingsDisplay(message.data.settings);
+            saveSession(message.data.token);
             document.getElementById('room-code').textContent = currentRoomId;
             showScreen('lobby');
+            startClockSync();
             break;
 
         case 'room_joined':
             currentRoomId = message.roomId;
             currentPlayerId = message.data.playerId;
+            hostId = message.data.hostId;
+            updateHostControls();
+            updateSettingsDisplay(message.data.settings);
+            saveSession(message.data.token);
             document.getElementById('room-code').textContent = currentRoomId;
-            showScreen('lobby');
+            if (!screens.end.classList.contains('active')) {
+                showScreen('lobby');
+            }
+            isSpectator = false;
+            updateSpectatorView();
+            startClockSync();
+            break;
+
+        case 'spectator_joined':
+            currentRoomId = message.roomId;
+            currentPlayerId = message.data.spectatorId;
+            hostId = message.data.hostId;
+            isSpectator = true;
+            updateSettingsDisplay(message.data.settings);
+            updateSpectatorView();
+            document.getElementById('room-code').textContent = currentRoomId;
+            currentPhase = message.data.phase || 'lobby';
+            showScreen(message.data.gameStarted ? 'game' : 'lobby');
+            if (message.data.round) {
+                resumeRound(message.data.round, message.data.settings);
+            }
+            startClockSync();
+            break;
+
+        case 'rejoined':
+            restoreState(message);
+            startClockSync();
+            break;
+
+        case 'clock_sync':
+            handleClockSync(message.data);
+            break;
+
+        case 'phase':
+            handlePhase(message.data.phase);
+            break;
+
+        case 'host_changed':
+            hostId = message.data.hostId;
+            updateHostControls();
+            break;
+
+        case 'settings':
+            updateSettingsDisplay(message.data);
+            break;
+
+        case 'round_paused':
+            timerPaused = message.data.paused;
+            document.getElementById('pause-btn').textContent = timerPaused ? '▶ Reprendre' : '⏸ Pause';
+            if (audio) {
+                if (timerPaused) {
+                    audio.pause();
+                } else {
+                    audio.play().catch(err => console.error('Audio play error:', err));
+                }
+            }
+            break;
+
+        case 'room_closed':
+            alert(message.data.reason === 'empty' ? 'La partie a été fermée' : 'La partie a été fermée pour inactivité');
+            leaveRoom();
+            break;
+
+        case 'kicked':
+            alert(message.data.reason === 'banned' ? 'Vous avez été banni de la partie' : 'Vous avez été exclu de la partie');
+            leaveRoom();
             break;
 
         case 'player_list':
+            if (message.data.hostId) {
+                hostId = message.data.hostId;
+                updateHostControls();
+            }
+            updateTeams(message.data.teams || [], message.data.players);
             updatePlayerList(message.data.players);
+            updateSpectators(message.data.spectators || []);
+            break;
+
+        case 'recipe_report':
+            handleRecipeReport(message.data);
             break;
 
         case 'game_start':
             document.getElementById('max-rounds').textContent = message.data.maxRounds;
+            currentMode = message.data.mode || 'classic';
+            document.getElementById('difficulty-label').textContent =
+                `• ${difficultyLabels[message.data.difficulty] || difficultyLabels.medium}`;
+            document.getElementById('answer-input').placeholder = modePlaceholders[currentMode] || modePlaceholders.classic;
+            document.getElementById('answer-section').style.display = currentMode === 'choice' ? 'none' : '';
+            document.getElementById('choices-container').classList.toggle('active', currentMode === 'choice');
             showScreen('game');
             break;
 
+        case 'round_tick':
+            handleRoundTick(message.data);
+            break;
+
+        case 'skip_votes':
+            document.getElementById('vote-skip-btn').textContent = `Passer (${message.data.votes}/${message.data.needed})`;
+            break;
+
         case 'round_start':
             startRound(message.data);
             break;
@@ -143,16 +463,76 @@ function handleMessage(message) {
             showCorrectNotification(message.data);
             break;
 
+        case 'wrong_answer':
+            if (message.data.guessId !== undefined) {
+                rejectedGuesses.push({ id: message.data.guessId, answer: message.data.answer });
+            }
+            showWrongNotification(message.data);
+            break;
+
+        case 'dispute_opened':
+            renderDispute(message.data);
+            break;
+
+        case 'dispute_votes':
+            updateDisputeStatus(message.data.disputeId,
+                `${message.data.yes} pour / ${message.data.no} contre (${message.data.needed} requis)`);
+            break;
+
+        case 'dispute_resolved':
+            resolveDisputeDisplay(message.data);
+            break;
+
+        case 'guess_received':
+            showInfoNotification(message.data.optionId ? '📝 Réponse enregistrée' : `📅 Réponse enregistrée : ${message.data.guess}`);
+            document.getElementById('answer-input').disabled = true;
+            document.getElementById('submit-answer-btn').disabled = true;
+            break;
+
+        case 'player_guessed':
+            showInfoNotification(`${message.data.username} a proposé une année`);
+            break;
+
         case 'game_end':
             endGame(message.data);
             break;
 
         case 'error':
+            if (message.data.code === 'wrong_phase') {
+                console.warn(message.data.message);
+                break;
+            }
+            if (message.data.code === 'rejoin_failed') {
+                clearSession();
+                currentRoomId = null;
+                showScreen('home');
+                break;
+            }
             alert(message.data.message);
             break;
     }
 }
 
+function handlePhase(phase) {
+    const previous = currentPhase;
+    currentPhase = phase;
+
+    if (phase === 'loading') {
+        showInfoNotification('🎵 Chargement des morceaux...');
+    }
+
+    if (phase === 'lobby' && previous !== 'lobby') {
+        clearInterval(gameTimer);
+        if (audio) {
+            audio.pause();
+        }
+        const readyBtn = document.getElementById('ready-btn');
+        readyBtn.disabled = false;
+        readyBtn.textContent = 'Prêt !';
+        showScreen('lobby');
+    }
+}
+
 function showConfigScreen() {
     const usernameInput = document.getElementById('username-input');
     username = usernameInput.value.trim();
@@ -166,10 +546,43 @@ function showConfigScreen() {
     showScreen('config');
 }
 
+function scoringPolicy(curve, bonus) {
+    return {
+        curve: curve,
+        firstBonus: bonus ? 200 : 0,
+        streakStep: bonus ? 0.1 : 0
+    };
+}
+
+function formatBreakdown(breakdown) {
+    if (!breakdown) {
+        return '';
+    }
+    const parts = [];
+    if (breakdown.firstBonus) {
+        parts.push(`premier +${breakdown.firstBonus}`);
+    }
+    if (breakdown.multiplier > 1) {
+        parts.push(`série x${breakdown.multiplier.toFixed(1)}`);
+    }
+    if (breakdown.difficulty && breakdown.difficulty !== 1) {
+        parts.push(`difficulté x${breakdown.difficulty.toFixed(1)}`);
+    }
+    return parts.length ? ` (${parts.join(', ')})` : '';
+}
+
 function createRoom() {
     const playlist = document.getElementById('playlist-select').value;
+    const mode = document.getElementById('mode-select').value;
+    const teams = parseInt(document.getElementById('teams-select').value);
+    const aggregation = document.getElementById('aggregation-select').value;
+    const difficulty = document.getElementById('difficulty-select').value;
+    const recipe = currentRecipe();
+    const scoring = document.getElementById('scoring-select').value;
+    const bonus = document.getElementById('bonus-input').checked;
     const rounds = parseInt(document.getElementById('rounds-input').value);
     const time = parseInt(document.getElementById('time-input').value);
+    const intermission = parseInt(document.getElementById('intermission-input').value);
 
     if (rounds < 1 || rounds > 20) {
         alert('Le nombre de manches doit être entre 1 et 20');
@@ -185,8 +598,16 @@ function createRoom() {
     
     lastGameConfig = {
         playlist: playlist,
+        mode: mode,
+        teams: teams,
+        aggregation: aggregation,
+        difficulty: difficulty,
+        recipe: recipe,
+        scoring: scoring,
+        bonus: bonus,
         rounds: rounds,
-        time: time
+        time: time,
+        intermission: intermission
     };
 
     connectWebSocket();
@@ -196,13 +617,20 @@ function createRoom() {
             type: 'create_room',
             username: username,
             playlist: playlist,
+            mode: mode,
+            teamCount: teams,
+            teamAggregation: aggregation,
+            difficulty: difficulty,
+            recipe: recipe,
+            scoring: scoringPolicy(scoring, bonus),
             maxRounds: rounds,
-            roundTime: time
+            roundTime: time,
+            intermission: intermission
         }));
     };
 }
 
-function joinRoom() {
+function joinRoom(spectate) {
     const usernameInput = document.getElementById('username-input');
     const roomCodeInput = document.getElementById('room-code-input');
     username = usernameInput.value.trim();
@@ -224,7 +652,8 @@ function joinRoom() {
         ws.send(JSON.stringify({
             type: 'join_room',
             username: username,
-            roomId: roomCode
+            roomId: roomCode,
+            spectate: spectate
         }));
     };
 }
@@ -240,6 +669,8 @@ function setReady() {
 }
 
 function leaveRoom() {
+    clearSession();
+    intentionalClose = true;
     if (ws) {
         ws.close();
     }
@@ -291,7 +722,24 @@ function updatePlayerList(players) {
                 scoreSpan.textContent = `${player.score} pts`;
                 
                 playerDiv.appendChild(nameSpan);
+
+                if (player.team && teamNamesById[player.team]) {
+                    const teamSpan = document.createElement('span');
+                    teamSpan.className = 'player-team';
+                    teamSpan.textContent = teamNamesById[player.team];
+                    nameSpan.appendChild(teamSpan);
+                }
                 
+                if (isHost() && player.id !== currentPlayerId && container === document.getElementById('players-container')) {
+                    ['kick', 'ban'].forEach(action => {
+                        const actionBtn = document.createElement('button');
+                        actionBtn.className = 'btn btn-small player-action';
+                        actionBtn.textContent = action === 'kick' ? 'Exclure' : 'Bannir';
+                        actionBtn.addEventListener('click', () => sendHostCommand(action, { targetId: player.id }));
+                        nameSpan.appendChild(actionBtn);
+                    });
+                }
+
                 if (player.ready && container === document.getElementById('players-container')) {
                     const readySpan = document.createElement('span');
                     readySpan.className = 'player-ready';
@@ -307,31 +755,107 @@ function updatePlayerList(players) {
     });
 }
 
+function updateTeams(teams, players) {
+    const container = document.getElementById('teams-container');
+    container.innerHTML = '';
+    teamNamesById = {};
+
+    const me = players.find(p => p.id === currentPlayerId);
+
+    teams.forEach(team => {
+        teamNamesById[team.id] = team.name;
+
+        const teamDiv = document.createElement('div');
+        teamDiv.className = 'team-card';
+        if (me && me.team === team.id) {
+            teamDiv.classList.add('mine');
+        }
+        teamDiv.textContent = `${team.name} — ${team.score} pts (${team.members.length})`;
+        teamDiv.addEventListener('click', () => chooseTeam(team.id));
+        container.appendChild(teamDiv);
+    });
+}
+
+function chooseTeam(teamId) {
+    if (ws && ws.readyState === WebSocket.OPEN) {
+        ws.send(JSON.stringify({
+            type: 'choose_team',
+            team: teamId
+        }));
+    }
+}
+
 function startRound(data) {
     if (screens.roundEnd.classList.contains('active')) {
         showScreen('game');
     }
 
     document.getElementById('current-round').textContent = data.round;
+    rejectedGuesses = [];
     document.getElementById('answer-input').value = '';
-    document.getElementById('answer-input').disabled = false;
-    document.getElementById('submit-answer-btn').disabled = false;
+    document.getElementById('answer-input').disabled = isSpectator;
+    document.getElementById('submit-answer-btn').disabled = isSpectator;
 
+    const delay = data.playAt ? Math.max(0, data.playAt - serverNow()) : 0;
+    playbackAcked = false;
+    clearTimeout(playTimeout);
     if (audio) {
         audio.src = data.preview;
-        audio.play().catch(err => console.error('Audio play error:', err));
+        audio.load();
+        playTimeout = setTimeout(() => {
+            audio.play().catch(err => console.error('Audio play error:', err));
+        }, delay);
     }
 
+    renderChoices(data.options || []);
+
     const vinyl = document.getElementById('vinyl');
     vinyl.classList.add('spinning');
 
-    startTimer();
+    startTimer(-delay / 1000);
+}
+
+function renderChoices(options) {
+    const container = document.getElementById('choices-container');
+    container.innerHTML = '';
+
+    options.forEach(option => {
+        const btn = document.createElement('button');
+        btn.className = 'btn btn-secondary choice-btn';
+        btn.dataset.optionId = option.id;
+        btn.textContent = option.title;
+
+        const artistSpan = document.createElement('span');
+        artistSpan.className = 'choice-artist';
+        artistSpan.textContent = option.artist;
+        btn.appendChild(artistSpan);
+
+        btn.addEventListener('click', () => submitChoice(option.id, btn));
+        container.appendChild(btn);
+    });
+}
+
+function submitChoice(optionId, btn) {
+    if (!ws || ws.readyState !== WebSocket.OPEN) {
+        return;
+    }
+
+    ws.send(JSON.stringify({
+        type: 'answer',
+        answer: optionId
+    }));
+
+    document.querySelectorAll('.choice-btn').forEach(b => {
+        b.disabled = true;
+    });
+    btn.classList.add('selected');
 }
 
 function endRound(data) {
     if (gameTimer) {
         clearInterval(gameTimer);
     }
+    clearTimeout(playTimeout);
 
     const vinyl = document.getElementById('vinyl');
     vinyl.classList.remove('spinning');
@@ -346,14 +870,169 @@ function endRound(data) {
     document.getElementById('track-title').textContent = data.title;
     document.getElementById('track-artist').textContent = data.artist;
     document.getElementById('track-album').textContent = `Album: ${data.album}`;
+    document.getElementById('track-year').textContent = data.year ? `Année: ${data.year}` : '';
+
+    const cover = document.getElementById('track-cover');
+    cover.classList.toggle('hidden', !data.cover);
+    if (data.cover) {
+        cover.src = data.cover;
+    } else {
+        cover.removeAttribute('src');
+    }
+    const details = [];
+    if (data.explicit) {
+        details.push('🅴 Explicite');
+    }
+    if (data.bpm) {
+        details.push(`${Math.round(data.bpm)} BPM`);
+    }
+    document.getElementById('track-details').textContent = details.join(' • ');
+    const link = document.getElementById('track-link');
+    link.classList.toggle('hidden', !data.link);
+    link.href = data.link || '#';
+
+    if (data.correctOptionId) {
+        document.querySelectorAll('.choice-btn').forEach(btn => {
+            btn.disabled = true;
+            btn.classList.toggle('correct', btn.dataset.optionId === data.correctOptionId);
+        });
+    }
+
+    const guessesContainer = document.getElementById('round-guesses');
+    guessesContainer.innerHTML = '';
+    (data.picks || []).forEach(pick => {
+        const pickDiv = document.createElement('div');
+        pickDiv.className = 'player-item';
+        pickDiv.textContent = `${pick.username} : ${pick.correct ? '✅' : '❌'} ${pick.points > 0 ? '+' : ''}${pick.points} pts`;
+        guessesContainer.appendChild(pickDiv);
+    });
+    (data.guesses || []).forEach(guess => {
+        const guessDiv = document.createElement('div');
+        guessDiv.className = 'player-item';
+        guessDiv.textContent = `${guess.username} : ${guess.guess} (écart ${guess.distance}) +${guess.points} pts`;
+        guessesContainer.appendChild(guessDiv);
+    });
+
+    renderRejectedGuesses();
+    document.getElementById('disputes-container').innerHTML = '';
+
+    const voteBtn = document.getElementById('vote-skip-btn');
+    voteBtn.disabled = isSpectator;
+    voteBtn.textContent = 'Passer';
+    document.getElementById('next-round-info').textContent =
+        data.intermission ? `Manche suivante dans ${data.intermission}s` : '';
 
     showScreen('roundEnd');
 }
 
+function renderRejectedGuesses() {
+    const container = document.getElementById('my-guesses');
+    container.innerHTML = '';
+    const fields = currentMode === 'classic' ? ['title', 'artist'] : (disputeFieldLabels[currentMode] ? [currentMode] : []);
+    if (isSpectator || fields.length === 0) {
+        return;
+    }
+
+    rejectedGuesses.forEach(guess => {
+        const guessDiv = document.createElement('div');
+        guessDiv.className = 'player-item';
+        guessDiv.textContent = `❌ ${guess.answer} `;
+
+        fields.forEach(field => {
+            const btn = document.createElement('button');
+            btn.className = 'btn btn-secondary dispute-btn';
+            btn.textContent = fields.length > 1 ? `Contester (${disputeFieldLabels[field]})` : 'Contester';
+            btn.addEventListener('click', () => {
+                if (ws && ws.readyState === WebSocket.OPEN) {
+                    ws.send(JSON.stringify({ type: 'dispute', guessId: guess.id, field: field }));
+                }
+                container.querySelectorAll('.dispute-btn').forEach(b => {
+                    b.disabled = true;
+                });
+            });
+            guessDiv.appendChild(btn);
+        });
+        container.appendChild(guessDiv);
+    });
+}
+
+function renderDispute(data) {
+    const disputeDiv = document.createElement('div');
+    disputeDiv.className = 'player-item';
+    disputeDiv.id = `dispute-${data.disputeId}`;
+
+    const text = document.createElement('span');
+    text.textContent = `⚖️ ${data.username} conteste « ${data.guess} » (${disputeFieldLabels[data.field] || data.field}) `;
+    disputeDiv.appendChild(text);
+
+    const status = document.createElement('span');
+    status.className = 'dispute-status';
+    status.textContent = `(${data.needed} vote(s) requis)`;
+    disputeDiv.appendChild(status);
+
+    const addButton = (label, onClick) => {
+        const btn = document.createElement('button');
+        btn.className = 'btn btn-secondary dispute-vote-btn';
+        btn.textContent = label;
+        btn.addEventListener('click', () => {
+            onClick();
+            disputeDiv.querySelectorAll('.dispute-vote-btn').forEach(b => {
+                b.disabled = true;
+            });
+        });
+        disputeDiv.appendChild(btn);
+    };
+
+    if (data.playerId !== currentPlayerId && !isSpectator) {
+        const vote = accept => {
+            if (ws && ws.readyState === WebSocket.OPEN) {
+                ws.send(JSON.stringify({ type: 'dispute_vote', disputeId: data.disputeId, accept: accept }));
+            }
+        };
+        addButton('👍 Valider', () => vote(true));
+        addButton('👎 Refuser', () => vote(false));
+        if (isHost()) {
+            addButton('✅ Accepter (hôte)', () => sendHostCommand('resolve_dispute', { disputeId: data.disputeId, accept: true }));
+            addButton('⛔ Rejeter (hôte)', () => sendHostCommand('resolve_dispute', { disputeId: data.disputeId, accept: false }));
+        }
+    }
+
+    document.getElementById('disputes-container').appendChild(disputeDiv);
+}
+
+function updateDisputeStatus(disputeId, text) {
+    const disputeDiv = document.getElementById(`dispute-${disputeId}`);
+    if (disputeDiv) {
+        disputeDiv.querySelector('.dispute-status').textContent = text;
+    }
+}
+
+function resolveDisputeDisplay(data) {
+    const disputeDiv = document.getElementById(`dispute-${data.disputeId}`);
+    if (disputeDiv) {
+        disputeDiv.querySelectorAll('.dispute-vote-btn').forEach(b => b.remove());
+    }
+    updateDisputeStatus(data.disputeId, data.accepted ? `✅ Acceptée (+${data.points || 0} pts)` : '⛔ Refusée');
+    if (data.username) {
+        showInfoNotification(data.accepted
+            ? `⚖️ Réponse de ${data.username} acceptée : +${data.points} pts`
+            : `⚖️ Contestation de ${data.username} refusée`);
+    }
+}
+
 function endGame(data) {
     const resultsContainer = document.getElementById('results-container');
     resultsContainer.innerHTML = '';
 
+    const teamResults = document.getElementById('team-results-container');
+    teamResults.innerHTML = '';
+    (data.teams || []).forEach((team, index) => {
+        const teamDiv = document.createElement('div');
+        teamDiv.className = 'team-card';
+        teamDiv.textContent = `#${index + 1} ${team.name} — ${team.score} pts`;
+        teamResults.appendChild(teamDiv);
+    });
+
     const sortedPlayers = data.players.sort((a, b) => b.score - a.score);
 
     sortedPlayers.forEach((player, index) => {
@@ -399,6 +1078,18 @@ function showCorrectNotification(data) {
     let icon = '';
     
     switch(data.answerType) {
+        case 'title':
+            message = `${data.username} a trouvé le titre! +${data.points} pts`;
+            icon = '✅';
+            break;
+        case 'artist':
+            message = `${data.username} a trouvé l'artiste! +${data.points} pts`;
+            icon = '✅';
+            break;
+        case 'album':
+            message = `${data.username} a trouvé l'album! +${data.points} pts`;
+            icon = '✅';
+            break;
         case 'both':
             message = `${data.username} a trouvé titre + artiste! +${data.points} pts`;
             icon = '✅✅';
@@ -412,11 +1103,11 @@ function showCorrectNotification(data) {
             icon = '✅';
             break;
         case 'title_partial':
-            message = `${data.username} a trouvé le titre! +${data.points} pts (moitié)`;
+            message = `${data.username} a trouvé le titre! +${data.points} pts (partiel)`;
             icon = '🟠';
             break;
         case 'artist_partial':
-            message = `${data.username} a trouvé l'artiste! +${data.points} pts (moitié)`;
+            message = `${data.username} a trouvé l'artiste! +${data.points} pts (partiel)`;
             icon = '🟠';
             break;
         default:
@@ -424,7 +1115,7 @@ function showCorrectNotification(data) {
             icon = '✅';
     }
     
-    notification.textContent = `${icon} ${message}`;
+    notification.textContent = `${icon} ${message}${formatBreakdown(data.breakdown)}`;
     notification.style.background = data.color === 'green' ? '#00b893db' : '#ff8c00e6';
     notification.classList.add('show');
 
@@ -433,20 +1124,184 @@ function showCorrectNotification(data) {
     }, 3000);
 }
 
-function startTimer() {
-    let elapsed = 0;
+function showWrongNotification(data) {
+    const notification = document.getElementById('correct-notification');
+
+    if (data.duplicate && data.remainingGuesses > 0) {
+        notification.textContent = `🔁 Déjà trouvé — ${data.remainingGuesses} essai(s) restant(s)`;
+    } else if (data.remainingGuesses <= 0) {
+        notification.textContent = '⛔ Plus aucun essai pour cette manche';
+        document.getElementById('answer-input').disabled = true;
+        document.getElementById('submit-answer-btn').disabled = true;
+    } else {
+        notification.textContent = `❌ « ${data.answer} » — ${data.remainingGuesses} essai(s) restant(s)`;
+    }
+
+    notification.style.background = '#e0245ee6';
+    notification.classList.add('show');
+
+    setTimeout(() => {
+        notification.classList.remove('show');
+    }, 2000);
+}
+
+function showInfoNotification(text) {
+    const notification = document.getElementById('correct-notification');
+    notification.textContent = text;
+    notification.style.background = '#5b6ee1e6';
+    notification.classList.add('show');
+
+    setTimeout(() => {
+        notification.classList.remove('show');
+    }, 2000);
+}
+
+function restoreState(message) {
+    const data = message.data;
+    reconnectAttempts = 0;
+    currentRoomId = message.roomId;
+    currentPlayerId = data.playerId;
+    hostId = data.hostId;
+    document.getElementById('room-code').textContent = currentRoomId;
+    updateHostControls();
+    updateSettingsDisplay(data.settings);
+
+    currentPhase = data.phase || 'lobby';
+    if (!data.gameStarted || !data.round) {
+        const readyBtn = document.getElementById('ready-btn');
+        readyBtn.disabled = data.ready;
+        readyBtn.textContent = data.ready ? 'En attente...' : 'Prêt !';
+        showScreen('lobby');
+        return;
+    }
+
+    const round = data.round;
+    if (!resumeRound(round, data.settings)) {
+        return;
+    }
+
+    const locked = round.completed || round.choice || round.yearGuess || round.remainingGuesses <= 0;
+    document.getElementById('answer-input').disabled = !!locked;
+    document.getElementById('submit-answer-btn').disabled = !!locked;
+    if (round.choice) {
+        document.querySelectorAll('.choice-btn').forEach(btn => {
+            btn.disabled = true;
+            btn.classList.toggle('selected', btn.dataset.optionId === round.choice);
+        });
+    }
+}
+
+function resumeRound(round, settings) {
+    currentMode = round.mode || 'classic';
+    document.getElementById('max-rounds').textContent = round.maxRounds;
+    document.getElementById('difficulty-label').textContent =
+        `• ${difficultyLabels[settings && settings.difficulty] || difficultyLabels.medium}`;
+    document.getElementById('current-round').textContent = round.round;
+    document.getElementById('answer-input').placeholder = modePlaceholders[currentMode] || modePlaceholders.classic;
+    document.getElementById('answer-section').style.display = currentMode === 'choice' ? 'none' : '';
+    document.getElementById('choices-container').classList.toggle('active', currentMode === 'choice');
+    renderChoices(round.options || []);
+    showScreen('game');
+
+    if (round.elapsed >= round.roundTime) {
+        return false;
+    }
+
+    if (audio) {
+        audio.src = round.preview;
+        audio.currentTime = round.elapsed;
+        if (!round.paused) {
+            audio.play().catch(err => console.error('Audio play error:', err));
+        }
+    }
+    document.getElementById('vinyl').classList.add('spinning');
+
+    timerDuration = round.roundTime;
+    startTimer(round.elapsed);
+    timerPaused = round.paused;
+    return true;
+}
+
+function serverNow() {
+    return Date.now() + clockOffset;
+}
+
+function startClockSync() {
+    if (clockSyncTimer) {
+        clearInterval(clockSyncTimer);
+    }
+    bestClockRtt = Infinity;
+
+    const sample = () => {
+        if (!ws || ws.readyState !== WebSocket.OPEN) {
+            return;
+        }
+        ws.send(JSON.stringify({ type: 'clock_sync', clientTime: Date.now() }));
+    };
+    for (let i = 0; i < 5; i++) {
+        setTimeout(sample, i * 250);
+    }
+    clockSyncTimer = setInterval(sample, 30000);
+}
+
+function handleClockSync(data) {
+    const now = Date.now();
+    const rtt = now - data.clientTime;
+    if (rtt < 0 || rtt > bestClockRtt * 2 + 50) {
+        return;
+    }
+    bestClockRtt = Math.min(bestClockRtt, rtt);
+    clockOffset = data.serverTime - (data.clientTime + rtt / 2);
+}
+
+function acknowledgePlayback() {
+    if (playbackAcked || isSpectator || currentPhase !== 'playing') {
+        return;
+    }
+    playbackAcked = true;
+    if (ws && ws.readyState === WebSocket.OPEN) {
+        ws.send(JSON.stringify({ type: 'playback_started', startedAt: Math.round(serverNow()) }));
+    }
+}
+
+function voteSkip() {
+    if (ws && ws.readyState === WebSocket.OPEN) {
+        ws.send(JSON.stringify({ type: 'vote_skip' }));
+    }
+    document.getElementById('vote-skip-btn').disabled = true;
+}
+
+function handleRoundTick(data) {
+    if (data.phase === 'playing') {
+        timerElapsed = Math.max(0, timerDuration - data.remaining);
+        return;
+    }
+    if (data.phase === 'reveal') {
+        document.getElementById('next-round-info').textContent =
+            `Manche suivante dans ${Math.ceil(data.remaining)}s`;
+    }
+}
+
+function startTimer(initialElapsed = 0) {
+    timerElapsed = initialElapsed;
     const timerBar = document.getElementById('timer-bar');
 
     if (gameTimer) {
         clearInterval(gameTimer);
     }
 
+    timerPaused = false;
+    document.getElementById('pause-btn').textContent = '⏸ Pause';
+
     gameTimer = setInterval(() => {
-        elapsed += 0.1;
-        const percentage = 100 - (elapsed / timerDuration * 100);
+        if (timerPaused) {
+            return;
+        }
+        timerElapsed += 0.1;
+        const percentage = Math.min(100, 100 - (timerElapsed / timerDuration * 100));
         timerBar.style.width = percentage + '%';
 
-        if (elapsed >= timerDuration) {
+        if (timerElapsed >= timerDuration) {
             clearInterval(gameTimer);
         }
     }, 100);
@@ -462,9 +1317,190 @@ function showScreen(screenName) {
     }
 }
 
+function addRecipePart() {
+    const row = document.createElement('div');
+    row.className = 'player-item recipe-part';
+
+    const genre = document.createElement('select');
+    genre.className = 'config-select recipe-genre';
+    genre.innerHTML = '<option value="">Tous genres</option>' +
+        Array.from(document.getElementById('playlist-select').options)
+            .filter(o => o.parentElement.tagName !== 'OPTGROUP' && o.value !== 'generale' && o.value !== 'francaise')
+            .map(o => `<option value="${o.value}">${o.textContent}</option>`)
+            .join('');
+
+    const decade = document.createElement('select');
+    decade.className = 'config-select recipe-decade';
+    decade.innerHTML = '<option value="0">Toutes époques</option>' +
+        [1960, 1970, 1980, 1990, 2000, 2010, 2020].map(d => `<option value="${d}">Années ${d >= 2000 ? d : d % 100}</option>`).join('');
+
+    const artists = document.createElement('input');
+    artists.className = 'config-input recipe-artists';
+    artists.placeholder = 'Artistes (séparés par des virgules)';
+
+    const weight = document.createElement('input');
+    weight.type = 'number';
+    weight.className = 'config-input recipe-weight';
+    weight.min = 1;
+    weight.max = 10;
+    weight.value = 1;
+    weight.title = 'Poids';
+
+    const remove = document.createElement('button');
+    remove.className = 'btn btn-small';
+    remove.textContent = '✕';
+    remove.addEventListener('click', () => row.remove());
+
+    [genre, decade, artists, weight, remove].forEach(el => row.appendChild(el));
+    document.getElementById('recipe-parts').appendChild(row);
+}
+
+function currentRecipe() {
+    const parts = Array.from(document.querySelectorAll('#recipe-parts .recipe-part')).map(row => ({
+        genre: row.querySelector('.recipe-genre').value,
+        decade: parseInt(row.querySelector('.recipe-decade').value) || 0,
+        artists: row.querySelector('.recipe-artists').value.split(',').map(a => a.trim()).filter(a => a),
+        weight: parseFloat(row.querySelector('.recipe-weight').value) || 1
+    })).filter(p => p.genre || p.decade || p.artists.length);
+    return parts.length ? { parts: parts } : null;
+}
+
+function handleRecipeReport(data) {
+    if (data.filled) {
+        return;
+    }
+    const missing = data.parts
+        .filter(p => p.found < p.requested)
+        .map(p => `${p.label}: ${p.found}/${p.requested}`)
+        .join(', ');
+    showInfoNotification(`⚠️ Recette incomplète (${data.found}/${data.requested} titres) ${missing}`);
+}
+
+function loadSavedPlaylists(selected) {
+    fetch('/api/blindtest/playlists')
+        .then(res => res.ok ? res.json() : { playlists: [] })
+        .then(data => {
+            savedPlaylists = data.playlists || [];
+            ['playlist-select', 'lobby-playlist-select'].forEach(id => {
+                const select = document.getElementById(id);
+                const current = select.value;
+                select.querySelectorAll('optgroup').forEach(group => group.remove());
+                if (savedPlaylists.length === 0) {
+                    return;
+                }
+                const group = document.createElement('optgroup');
+                group.label = 'Playlists enregistrées';
+                savedPlaylists.forEach(playlist => {
+                    const option = document.createElement('option');
+                    option.value = playlist.key;
+                    option.textContent = `${playlist.name} (${playlist.trackCount} titres)`;
+                    group.appendChild(option);
+                });
+                select.appendChild(group);
+                select.value = id === 'playlist-select' && selected ? selected : current;
+            });
+            updateGenreDescription();
+        })
+        .catch(() => {});
+}
+
+function searchTracks() {
+    const query = document.getElementById('builder-search-input').value.trim();
+    if (!query) {
+        return;
+    }
+    fetch(`/api/blindtest/tracks/search?q=${encodeURIComponent(query)}`)
+        .then(res => res.ok ? res.json() : { tracks: [] })
+        .then(data => {
+            const container = document.getElementById('builder-results');
+            container.innerHTML = '';
+            (data.tracks || []).forEach(track => {
+                const div = document.createElement('div');
+                div.className = 'player-item';
+                div.textContent = `${track.title} - ${track.artist}`;
+                const add = document.createElement('button');
+                add.className = 'btn btn-small';
+                add.textContent = '+';
+                add.addEventListener('click', () => {
+                    if (!builderTracks.some(t => t.id === track.id)) {
+                        builderTracks.push(track);
+                        renderBuilderTracks();
+                    }
+                });
+                div.appendChild(add);
+                container.appendChild(div);
+            });
+        })
+        .catch(() => showInfoNotification('Recherche indisponible'));
+}
+
+function renderBuilderTracks() {
+    const container = document.getElementById('builder-tracks');
+    container.innerHTML = '';
+    builderTracks.forEach((track, i) => {
+        const div = document.createElement('div');
+        div.className = 'player-item';
+        div.textContent = `${i + 1}. ${track.title} - ${track.artist}`;
+        const remove = document.createElement('button');
+        remove.className = 'btn btn-small';
+        remove.textContent = '✕';
+        remove.addEventListener('click', () => {
+            builderTracks.splice(i, 1);
+            renderBuilderTracks();
+        });
+        div.appendChild(remove);
+        container.appendChild(div);
+    });
+}
+
+function savePlaylist() {
+    const name = document.getElementById('builder-name-input').value.trim();
+    if (!name) {
+        alert('Veuillez nommer la playlist');
+        return;
+    }
+    const deezerId = parseInt(document.getElementById('builder-deezer-input').value);
+    const artists = document.getElementById('builder-artists-input').value
+        .split(',')
+        .map(a => a.trim())
+        .filter(a => a);
+
+    fetch('/api/blindtest/playlists', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+            name: name,
+            shared: document.getElementById('builder-shared-input').checked,
+            trackIds: builderTracks.map(t => t.id),
+            deezerPlaylistId: deezerId > 0 ? deezerId : 0,
+            artists: artists
+        })
+    })
+        .then(res => {
+            if (!res.ok) {
+                throw new Error();
+            }
+            return res.json();
+        })
+        .then(playlist => {
+            builderTracks = [];
+            renderBuilderTracks();
+            document.getElementById('builder-results').innerHTML = '';
+            document.getElementById('playlist-builder').open = false;
+            showInfoNotification(`Playlist "${playlist.name}" enregistrée (${playlist.trackCount} titres)`);
+            loadSavedPlaylists(playlist.key);
+        })
+        .catch(() => alert("Impossible d'enregistrer la playlist"));
+}
+
 function updateGenreDescription() {
     const genre = document.getElementById('playlist-select').value;
     const descriptionElement = document.getElementById('genre-description');
+    const saved = savedPlaylists.find(p => p.key === genre);
+    if (saved) {
+        descriptionElement.textContent = `Playlist de ${saved.owner || 'un joueur'} • ${saved.trackCount} titres`;
+        return;
+    }
     
     const descriptions = {
         'generale': 'Un mélange de tous les genres musicaux pour une expérience variée (Pop, Rock, Jazz, Metal...)',
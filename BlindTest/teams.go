@@ -0,0 +1,136 @@
+package blindtest
+
+import "sort"
+
+const (
+	teamAggregationSum   = "sum"
+	teamAggregationFirst = "first"
+	maxTeams             = 4
+)
+
+var teamNames = []string{"Rouge", "Bleu", "Vert", "Jaune"}
+
+type Team struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+func setupTeams(room *Room, count int, aggregation string) {
+	if count < 2 {
+		return
+	}
+	if count > maxTeams {
+		count = maxTeams
+	}
+
+	room.Teams = make(map[string]*Team)
+	for i := 0; i < count; i++ {
+		id := string(rune('a' + i))
+		room.Teams[id] = &Team{ID: id, Name: teamNames[i]}
+	}
+
+	room.TeamAggregation = teamAggregationSum
+	if aggregation == teamAggregationFirst {
+		room.TeamAggregation = teamAggregationFirst
+	}
+	room.TeamFinders = make(map[string]string)
+}
+
+func assignTeam(room *Room, player *Player) {
+	if room.Teams == nil {
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, p := range room.Players {
+		if p.ID != player.ID && p.Team != "" {
+			counts[p.Team]++
+		}
+	}
+
+	best := ""
+	for _, id := range sortedTeamIDs(room) {
+		if best == "" || counts[id] < counts[best] {
+			best = id
+		}
+	}
+	player.Team = best
+}
+
+func chooseTeam(room *Room, player *Player, teamID string) bool {
+	if room.Players[player.ID] != player || room.Teams == nil || room.Phase != phaseLobby {
+		return false
+	}
+	if _, ok := room.Teams[teamID]; !ok {
+		return false
+	}
+	player.Team = teamID
+	return true
+}
+
+func awardPoints(room *Room, player *Player, points int) {
+	player.Score += points
+	if room.RoundPoints != nil {
+		room.RoundPoints[player.ID] += points
+	}
+
+	if room.Teams == nil {
+		return
+	}
+	team, ok := room.Teams[player.Team]
+	if !ok {
+		return
+	}
+
+	if room.TeamAggregation == teamAggregationFirst {
+		if points <= 0 {
+			return
+		}
+		finder, found := room.TeamFinders[team.ID]
+		if found && finder != player.ID {
+			return
+		}
+		room.TeamFinders[team.ID] = player.ID
+	}
+
+	team.Score += points
+}
+
+func sortedTeamIDs(room *Room) []string {
+	ids := make([]string, 0, len(room.Teams))
+	for id := range room.Teams {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func teamStandings(room *Room) []map[string]interface{} {
+	if room.Teams == nil {
+		return nil
+	}
+
+	standings := make([]map[string]interface{}, 0, len(room.Teams))
+	for _, id := range sortedTeamIDs(room) {
+		team := room.Teams[id]
+		members := make([]string, 0)
+		for _, p := range room.Players {
+			if p.Team == id {
+				members = append(members, p.Username)
+			}
+		}
+		sort.Strings(members)
+		standings = append(standings, map[string]interface{}{
+			"id":      team.ID,
+			"name":    team.Name,
+			"score":   team.Score,
+			"members": members,
+		})
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		return standings[i]["score"].(int) > standings[j]["score"].(int)
+	})
+	return standings
+}
@@ -1,26 +1,53 @@
 package blindtest
 
 import (
+	"context"
+	"strconv"
 	"sync"
 	"time"
 
-	"github.com/gorilla/websocket"
+	"groupie-tracker/wsclient"
 )
 
+type UserInfo struct {
+	ID     int
+	Pseudo string
+}
+
 type Player struct {
 	ID       string
+	UserID   int
 	Username string
-	Conn     *websocket.Conn
+	Client   *wsclient.Client
 	Score    int
 	Ready    bool
+	Team     string
+	JoinedAt time.Time
+
+	Token          string
+	Connected      bool
+	DisconnectedAt time.Time
+
+	Spectator   bool
+	WantsToPlay bool
+	Streak      int
+	PrevStreak  int
 }
 
 type Track struct {
-	Title    string `json:"title"`
-	Artist   string `json:"artist"`
-	Preview  string `json:"preview"`
-	Album    string `json:"album"`
-	Duration int    `json:"duration"`
+	ID            int64   `json:"id"`
+	Title         string  `json:"title"`
+	Artist        string  `json:"artist"`
+	Preview       string  `json:"preview"`
+	Album         string  `json:"album"`
+	AlbumID       int64   `json:"albumId"`
+	Duration      int     `json:"duration"`
+	ReleaseDate   string  `json:"releaseDate"`
+	Rank          int     `json:"rank"`
+	ChartPosition int     `json:"chartPosition,omitempty"`
+	Link          string  `json:"link,omitempty"`
+	Explicit      bool    `json:"explicit"`
+	BPM           float64 `json:"bpm,omitempty"`
 }
 
 type PlayerAnswer struct {
@@ -28,6 +55,20 @@ type PlayerAnswer struct {
 	FoundArtist bool
 	TimeTitle   time.Time
 	TimeArtist  time.Time
+	FoundAlbum  bool
+	TimeAlbum   time.Time
+	Guesses     int
+	YearGuess   int
+	Choice      string
+	TimeChoice  time.Time
+	Rejected    []RejectedGuess
+}
+
+type RejectedGuess struct {
+	Text     string
+	At       time.Time
+	Disputed bool
+	Accepted bool
 }
 
 type Room struct {
@@ -35,7 +76,9 @@ type Room struct {
 	Players         map[string]*Player
 	CurrentTrack    *Track
 	RoundNumber     int
-	GameStarted     bool
+	Phase           string
+	GameID          string
+	AudioToken      string
 	RoundStartTime  time.Time
 	CorrectAnswers  map[string]bool
 	PlayerAnswers   map[string]*PlayerAnswer
@@ -44,18 +87,87 @@ type Room struct {
 	MaxRounds       int
 	RoundTime       int
 	Playlist        string
-	mu              sync.RWMutex
+	Mode            string
+	ChoicePool      []Track
+	Options         []ChoiceOption
+	CorrectOption   string
+	Teams           map[string]*Team
+	TeamAggregation string
+	TeamFinders     map[string]string
+	RoundPoints     map[string]int
+	PlaybackStarts  map[string]time.Time
+	Scoring         ScoringPolicy
+	Difficulty      string
+	Recipe          *PlaylistRecipe
+	FirstFinder     string
+	Intermission    int
+	SkipVotes       map[string]bool
+	Disputes        map[string]*Dispute
+	PlayedTracks    map[int64]bool
+	HostID          string
+	Banned          map[string]bool
+	Paused          bool
+	PausedAt        time.Time
+
+	Spectators         map[string]*Player
+	AllowSpectatorJoin bool
+	MinCoverage        float64
+	MaxGuesses         int
+
+	ctx            context.Context
+	cancel         context.CancelFunc
+	lastActivity   time.Time
+	commands       chan command
+	done           chan struct{}
+	closed         bool
+	timer          *time.Timer
+	timerSeq       int
+	timerNext      func(*Room)
+	timerDeadline  time.Time
+	timerRemaining time.Duration
+	tickStop       chan struct{}
 }
 
 type Message struct {
-	Type      string                 `json:"type"`
-	Username  string                 `json:"username,omitempty"`
-	RoomID    string                 `json:"roomId,omitempty"`
-	Answer    string                 `json:"answer,omitempty"`
-	Playlist  string                 `json:"playlist,omitempty"`
-	MaxRounds int                    `json:"maxRounds,omitempty"`
-	RoundTime int                    `json:"roundTime,omitempty"`
-	Data      map[string]interface{} `json:"data,omitempty"`
+	Type         string                 `json:"type"`
+	Username     string                 `json:"username,omitempty"`
+	RoomID       string                 `json:"roomId,omitempty"`
+	Answer       string                 `json:"answer,omitempty"`
+	Playlist     string                 `json:"playlist,omitempty"`
+	MaxRounds    int                    `json:"maxRounds,omitempty"`
+	RoundTime    int                    `json:"roundTime,omitempty"`
+	Intermission int                    `json:"intermission,omitempty"`
+	Mode         string                 `json:"mode,omitempty"`
+	Scoring      *ScoringPolicy         `json:"scoring,omitempty"`
+	Difficulty   string                 `json:"difficulty,omitempty"`
+	Recipe       *PlaylistRecipe        `json:"recipe,omitempty"`
+	MinCoverage  float64                `json:"minCoverage,omitempty"`
+	MaxGuesses   int                    `json:"maxGuesses,omitempty"`
+	TeamCount    int                    `json:"teamCount,omitempty"`
+	Aggregation  string                 `json:"teamAggregation,omitempty"`
+	Team         string                 `json:"team,omitempty"`
+	TargetID     string                 `json:"targetId,omitempty"`
+	GuessID      int                    `json:"guessId,omitempty"`
+	Field        string                 `json:"field,omitempty"`
+	DisputeID    string                 `json:"disputeId,omitempty"`
+	Accept       bool                   `json:"accept,omitempty"`
+	Token        string                 `json:"token,omitempty"`
+	Spectate     bool                   `json:"spectate,omitempty"`
+	ClientTime   int64                  `json:"clientTime,omitempty"`
+	StartedAt    int64                  `json:"startedAt,omitempty"`
+	AllowJoin    *bool                  `json:"allowSpectatorJoin,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+}
+
+func (t *Track) Year() int {
+	if len(t.ReleaseDate) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(t.ReleaseDate[:4])
+	if err != nil {
+		return 0
+	}
+	return year
 }
 
 var (
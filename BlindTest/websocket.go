@@ -3,6 +3,9 @@ package blindtest
 import (
 	"log"
 	"net/http"
+	"time"
+
+	"groupie-tracker/wsclient"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -15,12 +18,18 @@ var upgrader = websocket.Upgrader{
 }
 
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	userID := 0
+	if user := currentUser(r); user != nil {
+		userID = user.ID
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("Upgrade error:", err)
 		return
 	}
-	defer conn.Close()
+	client := wsclient.New(conn)
+	defer client.Close()
 
 	playerID := uuid.New().String()
 	var currentRoom *Room
@@ -28,123 +37,123 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	for {
 		var msg Message
-		err := conn.ReadJSON(&msg)
+		err := client.ReadJSON(&msg)
 		if err != nil {
 			if currentRoom != nil && currentPlayer != nil {
-				handlePlayerDisconnect(currentRoom, currentPlayer)
+				sendCommand(currentRoom, leaveCommand{player: currentPlayer, client: client})
 			}
 			break
 		}
 
 		switch msg.Type {
 		case "create_room":
-			maxRounds := msg.MaxRounds
-			if maxRounds <= 0 || maxRounds > 20 {
-				maxRounds = 10
+			if !canUsePlaylist(msg.Playlist, userID) {
+				sendError(client, "Playlist not found")
+				continue
+			}
+			room := createRoom(msg)
+			player := &Player{
+				ID:       playerID,
+				UserID:   userID,
+				Username: msg.Username,
+				Client:   client,
+				JoinedAt: time.Now(),
 			}
-			roundTime := msg.RoundTime
-			if roundTime < 10 || roundTime > 60 {
-				roundTime = 30
+			if joinRoom(room, player, true, false) {
+				currentRoom = room
+				currentPlayer = player
 			}
-			playlist := msg.Playlist
-			if playlist == "" {
-				playlist = "pop"
+
+		case "join_room":
+			room := lookupRoom(msg.RoomID)
+			if room == nil {
+				sendError(client, "Room not found")
+				continue
 			}
 
-			room := createRoom(maxRounds, roundTime, playlist)
 			player := &Player{
 				ID:       playerID,
+				UserID:   userID,
 				Username: msg.Username,
-				Conn:     conn,
-				Score:    0,
-				Ready:    false,
+				Client:   client,
+				JoinedAt: time.Now(),
+			}
+			if joinRoom(room, player, false, msg.Spectate) {
+				currentRoom = room
+				currentPlayer = player
 			}
-			room.Players[playerID] = player
-			currentRoom = room
-			currentPlayer = player
-
-			conn.WriteJSON(Message{
-				Type:   "room_created",
-				RoomID: room.ID,
-				Data: map[string]interface{}{
-					"playerId": playerID,
-				},
-			})
-
-		case "join_room":
-			roomsMu.RLock()
-			room, exists := rooms[msg.RoomID]
-			roomsMu.RUnlock()
 
-			if !exists {
-				conn.WriteJSON(Message{
+		case "rejoin":
+			var player *Player
+			room := lookupRoom(msg.RoomID)
+			if room != nil {
+				player = rejoinRoom(room, msg.Token, client)
+			}
+			if player == nil {
+				client.Send(Message{
 					Type: "error",
 					Data: map[string]interface{}{
-						"message": "Room not found",
+						"message": "Reconnect failed",
+						"code":    "rejoin_failed",
 					},
 				})
 				continue
 			}
 
-			if room.GameStarted {
-				conn.WriteJSON(Message{
-					Type: "error",
-					Data: map[string]interface{}{
-						"message": "Game already started",
-					},
-				})
-				continue
+			currentRoom = room
+			currentPlayer = player
+			playerID = player.ID
+
+		case "join_as_player":
+			if currentRoom != nil && currentPlayer != nil {
+				sendCommand(currentRoom, playCommand{player: currentPlayer})
 			}
 
-			player := &Player{
-				ID:       playerID,
-				Username: msg.Username,
-				Conn:     conn,
-				Score:    0,
-				Ready:    false,
+		case "ready":
+			if currentRoom != nil && currentPlayer != nil {
+				sendCommand(currentRoom, readyCommand{player: currentPlayer})
 			}
 
-			room.mu.Lock()
-			room.Players[playerID] = player
-			room.mu.Unlock()
+		case "choose_team":
+			if currentRoom != nil && currentPlayer != nil {
+				sendCommand(currentRoom, teamCommand{player: currentPlayer, team: msg.Team})
+			}
 
-			currentRoom = room
-			currentPlayer = player
+		case "kick", "ban", "start_now", "pause", "resume", "skip", "update_settings", "play_again", "resolve_dispute":
+			if msg.Type == "update_settings" && !canUsePlaylist(msg.Playlist, userID) {
+				sendError(client, "Playlist not found")
+				continue
+			}
+			if currentRoom != nil && currentPlayer != nil {
+				sendCommand(currentRoom, hostCommand{player: currentPlayer, msg: msg})
+			}
 
-			conn.WriteJSON(Message{
-				Type:   "room_joined",
-				RoomID: room.ID,
-				Data: map[string]interface{}{
-					"playerId": playerID,
-				},
-			})
+		case "clock_sync":
+			client.Send(clockSyncReply(msg.ClientTime))
 
-			broadcastPlayerList(room)
+		case "playback_started":
+			if currentRoom != nil && currentPlayer != nil {
+				sendCommand(currentRoom, playbackCommand{player: currentPlayer, startedAt: msg.StartedAt, received: time.Now()})
+			}
 
-		case "ready":
+		case "vote_skip":
 			if currentRoom != nil && currentPlayer != nil {
-				currentPlayer.Ready = true
-				broadcastPlayerList(currentRoom)
+				sendCommand(currentRoom, voteCommand{player: currentPlayer})
+			}
 
-				allReady := true
-				currentRoom.mu.RLock()
-				playerCount := len(currentRoom.Players)
-				for _, p := range currentRoom.Players {
-					if !p.Ready {
-						allReady = false
-						break
-					}
-				}
-				currentRoom.mu.RUnlock()
+		case "dispute":
+			if currentRoom != nil && currentPlayer != nil {
+				sendCommand(currentRoom, disputeCommand{player: currentPlayer, guessID: msg.GuessID, field: msg.Field})
+			}
 
-				if allReady && playerCount >= 1 {
-					go startGame(currentRoom)
-				}
+		case "dispute_vote":
+			if currentRoom != nil && currentPlayer != nil {
+				sendCommand(currentRoom, disputeVoteCommand{player: currentPlayer, disputeID: msg.DisputeID, accept: msg.Accept})
 			}
 
 		case "answer":
-			if currentRoom != nil && currentPlayer != nil && currentRoom.GameStarted {
-				handleAnswer(currentRoom, currentPlayer, msg.Answer)
+			if currentRoom != nil && currentPlayer != nil {
+				sendCommand(currentRoom, answerCommand{player: currentPlayer, answer: msg.Answer})
 			}
 		}
 	}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/gorilla/websocket"
+	"groupie-tracker/wsclient"
 )
 
 func newRoom(code string) *Room {
@@ -13,7 +13,7 @@ func newRoom(code string) *Room {
 		reglages:    GameConfig{Categories: listeCategories(), Temps: 90, Manches: 5},
 		lettreActu:  lettreAleatoire(),
 		players:     make(map[string]*Player),
-		connections: make(map[*websocket.Conn]string),
+		connections: make(map[*wsclient.Client]string),
 	}
 }
 
@@ -27,7 +27,7 @@ func (r *Room) hasRoom() bool {
 	return len(r.players) < maxSalonPlayers
 }
 
-func (r *Room) addPlayer(conn *websocket.Conn) (*Player, error) {
+func (r *Room) addPlayer(conn *wsclient.Client) (*Player, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if len(r.players) >= maxSalonPlayers {
@@ -47,7 +47,7 @@ func (r *Room) addPlayer(conn *websocket.Conn) (*Player, error) {
 	return player, nil
 }
 
-func (r *Room) removePlayer(conn *websocket.Conn) {
+func (r *Room) removePlayer(conn *wsclient.Client) {
 	r.mu.Lock()
 	finalize := false
 	if id, ok := r.connections[conn]; ok {
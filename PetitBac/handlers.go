@@ -9,6 +9,8 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+
+	"groupie-tracker/wsclient"
 )
 
 type categoriesPageData struct {
@@ -210,19 +212,20 @@ func socketJeu(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
 	}
+	conn := wsclient.New(ws)
 
 	joueur, joinErr := room.addPlayer(conn)
 	if joinErr != nil {
-		conn.WriteJSON(map[string]string{"type": "error", "message": joinErr.Error()})
-		conn.Close()
+		conn.Send(map[string]string{"type": "error", "message": joinErr.Error()})
+		conn.CloseAfterFlush()
 		return
 	}
 
-	conn.WriteJSON(map[string]string{"type": "identity", "id": joueur.ID, "room": room.code})
+	conn.Send(map[string]string{"type": "identity", "id": joueur.ID, "room": room.code})
 	room.envoyerEtat()
 	go room.boucleWS(conn)
 }
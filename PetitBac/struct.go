@@ -3,7 +3,7 @@ package petitbac
 import (
 	"sync"
 
-	"github.com/gorilla/websocket"
+	"groupie-tracker/wsclient"
 )
 
 type Player struct {
@@ -14,7 +14,7 @@ type Player struct {
 	Reponses map[string]string `json:"-"`
 	Pret     bool              `json:"ready"`
 	Actif    bool              `json:"active"`
-	Conn     *websocket.Conn   `json:"-"`
+	Conn     *wsclient.Client  `json:"-"`
 }
 
 type Message struct {
@@ -68,7 +68,7 @@ type Room struct {
 	reglages          GameConfig
 	lettreActu        rune
 	players           map[string]*Player
-	connections       map[*websocket.Conn]string
+	connections       map[*wsclient.Client]string
 	tempsRest         int
 	mancheEnCours     bool
 	attenteVotes      bool
@@ -3,10 +3,10 @@ package petitbac
 import (
 	"strings"
 
-	"github.com/gorilla/websocket"
+	"groupie-tracker/wsclient"
 )
 
-func (r *Room) boucleWS(conn *websocket.Conn) {
+func (r *Room) boucleWS(conn *wsclient.Client) {
 	defer func() {
 		r.removePlayer(conn)
 		conn.Close()
@@ -85,7 +85,7 @@ func (r *Room) envoyerEtat() {
 		TempsParManche: r.reglages.Temps,
 	}
 	liste := make([]Player, 0, len(r.players))
-	dest := make([]*websocket.Conn, 0, len(r.players))
+	dest := make([]*wsclient.Client, 0, len(r.players))
 	for _, j := range r.players {
 		liste = append(liste, *j)
 		dest = append(dest, j.Conn)
@@ -131,7 +131,7 @@ func (r *Room) envoyerEtat() {
 
 	for _, c := range dest {
 		if c != nil {
-			c.WriteJSON(etat)
+			c.Send(etat)
 		}
 	}
 }
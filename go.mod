@@ -11,6 +11,7 @@ require (
 
 require (
 	golang.org/x/crypto v0.46.0
+	golang.org/x/text v0.32.0
 	modernc.org/sqlite v1.40.1
 )
 
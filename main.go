@@ -24,7 +24,9 @@ func main() {
 	if err := petitbac.RegisterRoutes(requireAuth, petitBacUserResolver); err != nil {
 		log.Fatal(err)
 	}
-	blindtest.RegisterRoutes(requireAuth)
+	if err := blindtest.RegisterRoutes(requireAuth, blindTestUserResolver); err != nil {
+		log.Fatal(err)
+	}
 
 	log.Println("SERVEUR PRET")
 
@@ -53,3 +55,11 @@ func petitBacUserResolver(r *http.Request) (*petitbac.UserInfo, error) {
 	}
 	return &petitbac.UserInfo{ID: user.ID, Pseudo: user.Pseudo}, nil
 }
+
+func blindTestUserResolver(r *http.Request) (*blindtest.UserInfo, error) {
+	user, err := getUserFromSession(r)
+	if err != nil {
+		return nil, err
+	}
+	return &blindtest.UserInfo{ID: user.ID, Pseudo: user.Pseudo}, nil
+}
@@ -0,0 +1,122 @@
+package wsclient
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = (pongWait * 9) / 10
+	sendBuffer = 64
+)
+
+var ErrClosed = errors.New("wsclient: connection closed")
+
+type Client struct {
+	conn      *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func New(conn *websocket.Conn) *Client {
+	c := &Client{
+		conn: conn,
+		send: make(chan []byte, sendBuffer),
+		done: make(chan struct{}),
+	}
+
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	go c.writeLoop()
+	return c
+}
+
+func (c *Client) ReadJSON(v interface{}) error {
+	err := c.conn.ReadJSON(v)
+	if err == nil {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	}
+	return err
+}
+
+func (c *Client) Send(v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.enqueue(payload)
+}
+
+func (c *Client) enqueue(payload []byte) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case c.send <- payload:
+		return nil
+	case <-c.done:
+		return ErrClosed
+	default:
+		c.Close()
+		return ErrClosed
+	}
+}
+
+func (c *Client) CloseAfterFlush() {
+	if err := c.enqueue(nil); err != nil {
+		c.Close()
+	}
+}
+
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) writeLoop() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Close()
+	}()
+
+	for {
+		select {
+		case payload := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if payload == nil {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}